@@ -0,0 +1,221 @@
+package game
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+type arenaShape int
+
+const (
+	arenaCircle arenaShape = iota
+	arenaPolygon
+	arenaRing
+)
+
+func (s arenaShape) String() string {
+	switch s {
+	case arenaCircle:
+		return "circle"
+	case arenaPolygon:
+		return "polygon"
+	case arenaRing:
+		return "ring"
+	}
+	return "unknown"
+}
+
+// Arena is a container collider: instead of keeping circles out, it keeps
+// them inside.
+type Arena struct {
+	shape       arenaShape
+	center      Vec2
+	radius      float64 // outer radius of circle and ring arenas
+	inner       float64 // radius of the solid island in the middle of a ring
+	points      []Vec2  // corners of a polygon arena
+	normals     []Vec2  // inward normal of the edge starting at each corner
+	restitution float64 // fraction of the normal speed kept after a bounce
+}
+
+// NewCircleArena keeps circles inside a circle.
+func NewCircleArena(center Vec2, radius, restitution float64) *Arena {
+	return &Arena{
+		shape:       arenaCircle,
+		center:      center,
+		radius:      radius,
+		restitution: restitution,
+	}
+}
+
+// NewRingArena keeps circles between two circles around center, like a
+// running track.
+func NewRingArena(center Vec2, inner, outer, restitution float64) *Arena {
+	return &Arena{
+		shape:       arenaRing,
+		center:      center,
+		radius:      outer,
+		inner:       inner,
+		restitution: restitution,
+	}
+}
+
+// NewPolygonArena keeps circles inside a convex polygon. The points can be in
+// either winding order.
+func NewPolygonArena(points []Vec2, restitution float64) *Arena {
+	a := &Arena{
+		shape:       arenaPolygon,
+		points:      points,
+		restitution: restitution,
+	}
+	n := len(points)
+	area := 0.0
+	for i, p := range points {
+		area += p.Cross(points[(i+1)%n])
+		a.center = a.center.Add(p.Scaled(1.0 / float64(n)))
+	}
+	// the inside is on the left of every edge for a positive area
+	sign := 1.0
+	if area < 0 {
+		sign = -1.0
+	}
+	for i, p := range points {
+		a.normals = append(a.normals, p.To(points[(i+1)%n]).Normal().Unit().Scaled(sign))
+	}
+	return a
+}
+
+func (a *Arena) String() string {
+	return a.shape.String()
+}
+
+// wallContact is a circle touching a wall that pushes it along normal.
+type wallContact struct {
+	i           int
+	normal      Vec2
+	velocity    Vec2    // of the wall's surface, for conveyor belts
+	restitution float64 // fraction of the normal speed kept after a bounce
+	grip        float64 // fraction of the sliding speed the surface takes away
+}
+
+// addArena adds a container to the engine.
+func (e *Engine) addArena(a *Arena) {
+	e.arenas = append(e.arenas, a)
+}
+
+// resolveArena pushes body i back inside the arena and records the walls it
+// touched for the velocity pass.
+func (e *Engine) resolveArena(a *Arena, i int) {
+	p := e.bodies.pos[i]
+	r := e.bodies.radius[i]
+	switch a.shape {
+	case arenaCircle, arenaRing:
+		d := a.center.To(p)
+		dist := d.Len()
+		var out Vec2
+		if dist == 0 {
+			out = Vec2{0, -1}
+		} else {
+			out = d.Scaled(1.0 / dist)
+		}
+		if dist+r > a.radius {
+			e.pushFromWall(i, out.Scaled(-1), dist+r-a.radius, a.restitution)
+		}
+		if a.shape == arenaRing && dist-r < a.inner {
+			e.pushFromWall(i, out, a.inner-dist+r, a.restitution)
+		}
+	case arenaPolygon:
+		// the polygon is convex, so being inside every edge is being inside
+		for k, corner := range a.points {
+			n := a.normals[k]
+			s := corner.To(p).Dot(n)
+			if s < r {
+				e.pushFromWall(i, n, r-s, a.restitution)
+				p = e.bodies.pos[i]
+			}
+		}
+	}
+}
+
+// pushFromWall moves body i depth along normal and records the contact.
+func (e *Engine) pushFromWall(i int, normal Vec2, depth, restitution float64) {
+	e.addContact(depth)
+	e.bodies.pos[i] = e.bodies.pos[i].Add(normal.Scaled(depth))
+	e.walls = append(e.walls, wallContact{i: i, normal: normal, restitution: restitution})
+}
+
+// bounceOffWalls removes the velocity of every circle going into a wall it
+// touched, and sends it back out scaled by the restitution. Walls with grip
+// also pull the circle's sliding speed towards the speed of their surface.
+func (e *Engine) bounceOffWalls() {
+	vel := e.bodies.vel
+	for _, w := range e.walls {
+		rel := vel[w.i].Sub(w.velocity)
+		vn := rel.Dot(w.normal)
+		if vn < 0 {
+			vel[w.i] = vel[w.i].Sub(w.normal.Scaled((1 + w.restitution) * vn))
+		}
+		if w.grip > 0 {
+			slide := rel.Sub(w.normal.Scaled(vn))
+			vel[w.i] = vel[w.i].Sub(slide.Scaled(w.grip))
+		}
+	}
+}
+
+// cycleArena switches between no arena and each of the preset arenas,
+// centered in the world.
+func (e *Engine) cycleArena() {
+	center := Vec2{e.width / 2, e.height / 2}
+	radius := math.Min(e.width, e.height) * 0.45
+	shape := arenaShape(-1)
+	if len(e.arenas) > 0 {
+		shape = e.arenas[0].shape
+	}
+	e.arenas = e.arenas[:0]
+	switch shape {
+	case -1:
+		e.addArena(NewCircleArena(center, radius, 0.9))
+	case arenaCircle:
+		var points []Vec2
+		for k := 0; k < 6; k++ {
+			angle := twoPi * float64(k) / 6
+			points = append(points, center.Add(Vec2{math.Cos(angle), math.Sin(angle)}.Scaled(radius)))
+		}
+		e.addArena(NewPolygonArena(points, 0.9))
+	case arenaPolygon:
+		e.addArena(NewRingArena(center, radius*0.35, radius, 0.9))
+	}
+}
+
+// Draw the walls of the arena just outside the space it keeps circles in.
+func (a *Arena) Draw(screen *ebiten.Image) {
+	const thickness = 6
+	clr := colorful.Hsl(0, 0, 0.5)
+	switch a.shape {
+	case arenaCircle, arenaRing:
+		drawCircleOutline(screen, a.center, a.radius+thickness/2, thickness, clr)
+		if a.shape == arenaRing {
+			drawCircleOutline(screen, a.center, a.inner-thickness/2, thickness, clr)
+		}
+	case arenaPolygon:
+		n := len(a.points)
+		for k, p := range a.points {
+			q := a.points[(k+1)%n]
+			offset := a.normals[k].Scaled(-thickness / 2)
+			drawLine(p.Add(offset), q.Add(offset), thickness, screen, clr, 1.0)
+		}
+	}
+}
+
+// drawCircleOutline draws a circle of line segments.
+func drawCircleOutline(screen *ebiten.Image, center Vec2, radius, thickness float64, clr colorful.Color) {
+	const segments = 64
+	prev := center.Add(Vec2{radius, 0})
+	for s := 1; s <= segments; s++ {
+		angle := twoPi * float64(s) / segments
+		next := center.Add(Vec2{math.Cos(angle), math.Sin(angle)}.Scaled(radius))
+		drawLine(prev, next, thickness, screen, clr, 1.0)
+		prev = next
+	}
+}
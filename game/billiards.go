@@ -0,0 +1,646 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+const (
+	poolFriction  = 0.06 // speed lost per frame rolling on the cloth
+	poolRest      = 0.05 // speed below which a ball counts as stopped
+	poolMaxShot   = 40.0
+	poolShotScale = 0.12 // shot speed per pixel the cue is pulled back
+)
+
+// poolColors are the colors of the cue ball and the balls 1 to 8. Stripes
+// use the color of their number minus eight.
+var poolColors = [...]colorful.Color{
+	{R: 0.95, G: 0.95, B: 0.9},
+	{R: 0.95, G: 0.8, B: 0.1},
+	{R: 0.1, G: 0.25, B: 0.75},
+	{R: 0.85, G: 0.1, B: 0.1},
+	{R: 0.4, G: 0.15, B: 0.6},
+	{R: 0.95, G: 0.5, B: 0.1},
+	{R: 0.1, G: 0.5, B: 0.25},
+	{R: 0.5, G: 0.1, B: 0.1},
+	{R: 0.05, G: 0.05, B: 0.05},
+}
+
+// poolBall is one of the balls on the table. Number 0 is the cue ball.
+type poolBall struct {
+	number int
+	circle *Circle
+	potted bool
+}
+
+func (b *poolBall) color() colorful.Color {
+	if b.number > 8 {
+		return poolColors[b.number-8]
+	}
+	return poolColors[b.number]
+}
+
+// poolGroup is the set of balls a player has to pot before the eight.
+type poolGroup int
+
+const (
+	groupOpen poolGroup = iota
+	groupSolids
+	groupStripes
+)
+
+func (g poolGroup) String() string {
+	switch g {
+	case groupOpen:
+		return "open"
+	case groupSolids:
+		return "solids"
+	case groupStripes:
+		return "stripes"
+	}
+	return "unknown"
+}
+
+func (g poolGroup) has(b *poolBall) bool {
+	switch g {
+	case groupSolids:
+		return b.number >= 1 && b.number <= 7
+	case groupStripes:
+		return b.number >= 9
+	}
+	return false
+}
+
+func (g poolGroup) other() poolGroup {
+	switch g {
+	case groupSolids:
+		return groupStripes
+	case groupStripes:
+		return groupSolids
+	}
+	return groupOpen
+}
+
+type poolState int
+
+const (
+	poolAiming  poolState = iota // waiting for the player to shoot
+	poolRolling                  // balls are moving
+	poolPlacing                  // the player has the cue ball in hand
+	poolOver
+)
+
+// billiards is a game of eight ball for two players on a pool table. The
+// rules are simplified: any foul gives the other player ball in hand
+// anywhere on the table, and potting the eight early loses straight away.
+type billiards struct {
+	width      float64
+	height     float64
+	engine     *Engine
+	shader     *ebiten.Shader
+	upperLeft  Vec2 // corners of the cloth inside the cushions
+	lowerRight Vec2
+	rails      []*Chain
+	pockets    []Vec2
+	pocket     float64 // radius of the pockets
+	radius     float64 // of the balls
+	balls      []*poolBall
+	byCircle   map[*Circle]*poolBall
+	cue        *poolBall
+	state      poolState
+	player     int
+	groups     [2]poolGroup
+	aiming     bool // the cue is being pulled back
+
+	// what happened during the shot in progress
+	breaking bool // it is the first shot of the rack
+	onEight  bool // the shooter had cleared their group before the shot
+	firstHit *poolBall
+	potted   []*poolBall
+	message  string
+}
+
+// newBilliards lays out a table twice as long as it is wide in the middle of
+// the screen and racks the balls.
+func newBilliards(width, height int, shader *ebiten.Shader) *billiards {
+	w := float64(width)
+	h := float64(height)
+	length := math.Min(w*0.8, h*0.7*2)
+	center := Vec2{w / 2, h / 2}
+	half := Vec2{length / 2, length / 4}
+
+	e := NewEngine(width, height, nil, nil, nil)
+	e.drag = Drag{DragRolling, poolFriction}
+
+	bl := &billiards{
+		width:      w,
+		height:     h,
+		engine:     e,
+		shader:     shader,
+		upperLeft:  center.Sub(half),
+		lowerRight: center.Add(half),
+		radius:     length * 0.01125,
+	}
+	bl.pocket = bl.radius * 1.8
+
+	// six cushions with gaps for the pockets in the corners and in the
+	// middle of the long sides
+	ul := bl.upperLeft
+	lr := bl.lowerRight
+	corner := bl.radius * 2.2
+	side := bl.radius * 1.8
+	bl.rails = []*Chain{
+		NewChain([]Vec2{{ul.X + corner, ul.Y}, {center.X - side, ul.Y}}, 4, false),
+		NewChain([]Vec2{{center.X + side, ul.Y}, {lr.X - corner, ul.Y}}, 4, false),
+		NewChain([]Vec2{{lr.X, ul.Y + corner}, {lr.X, lr.Y - corner}}, 4, false),
+		NewChain([]Vec2{{lr.X - corner, lr.Y}, {center.X + side, lr.Y}}, 4, false),
+		NewChain([]Vec2{{center.X - side, lr.Y}, {ul.X + corner, lr.Y}}, 4, false),
+		NewChain([]Vec2{{ul.X, lr.Y - corner}, {ul.X, ul.Y + corner}}, 4, false),
+	}
+	for _, c := range bl.rails {
+		e.addChain(c)
+	}
+	bl.pockets = []Vec2{
+		ul, {center.X, ul.Y}, {lr.X, ul.Y},
+		{ul.X, lr.Y}, {center.X, lr.Y}, lr,
+	}
+
+	e.onTouch = func(a, b *Circle) {
+		if bl.state != poolRolling || bl.firstHit != nil {
+			return
+		}
+		if a == bl.cue.circle {
+			bl.firstHit = bl.byCircle[b]
+		} else if b == bl.cue.circle {
+			bl.firstHit = bl.byCircle[a]
+		}
+	}
+
+	bl.rack()
+	return bl
+}
+
+// rack clears the table and sets up a new game, with the fifteen balls in a
+// triangle on the foot spot and the cue ball on the head spot.
+func (bl *billiards) rack() {
+	for _, b := range bl.balls {
+		if b.circle.index >= 0 {
+			bl.engine.removeCircle(b.circle)
+		}
+	}
+	bl.balls = bl.balls[:0]
+	bl.byCircle = map[*Circle]*poolBall{}
+
+	// the eight goes in the middle and the back corners get one of each
+	// group, the rest are shuffled
+	var numbers [15]int
+	numbers[4] = 8
+	solids := rand.Perm(7)
+	stripes := rand.Perm(7)
+	corners := [2]int{solids[0] + 1, stripes[0] + 9}
+	if rand.Intn(2) == 0 {
+		corners[0], corners[1] = corners[1], corners[0]
+	}
+	numbers[10] = corners[0]
+	numbers[14] = corners[1]
+	var rest []int
+	for k := 1; k < 7; k++ {
+		rest = append(rest, solids[k]+1, stripes[k]+9)
+	}
+	rand.Shuffle(len(rest), func(a, b int) { rest[a], rest[b] = rest[b], rest[a] })
+	for k := range numbers {
+		if numbers[k] == 0 {
+			numbers[k] = rest[0]
+			rest = rest[1:]
+		}
+	}
+
+	length := bl.lowerRight.X - bl.upperLeft.X
+	mid := (bl.upperLeft.Y + bl.lowerRight.Y) / 2
+	d := bl.radius*2 + 0.5
+	slot := 0
+	for row := 0; row < 5; row++ {
+		for k := 0; k <= row; k++ {
+			x := bl.upperLeft.X + length*0.75 + float64(row)*d*math.Sqrt(3)/2
+			y := mid + (float64(k)-float64(row)/2)*d
+			bl.addBall(numbers[slot], Vec2{x, y})
+			slot++
+		}
+	}
+	bl.cue = bl.addBall(0, Vec2{bl.upperLeft.X + length*0.25, mid})
+
+	bl.state = poolAiming
+	bl.player = 0
+	bl.groups = [2]poolGroup{}
+	bl.breaking = true
+	bl.message = "Player 1 to break"
+}
+
+func (bl *billiards) addBall(number int, pos Vec2) *poolBall {
+	b := &poolBall{number: number, circle: NewCircle(pos.X, pos.Y, bl.radius, bl.shader)}
+	bl.engine.addCircle(b.circle)
+	bl.balls = append(bl.balls, b)
+	bl.byCircle[b.circle] = b
+	return b
+}
+
+// cleared reports whether player has potted every ball of their group.
+func (bl *billiards) cleared(player int) bool {
+	g := bl.groups[player]
+	if g == groupOpen {
+		return false
+	}
+	for _, b := range bl.balls {
+		if g.has(b) && !b.potted {
+			return false
+		}
+	}
+	return true
+}
+
+// left returns how many balls of group are still on the table.
+func (bl *billiards) left(g poolGroup) int {
+	n := 0
+	for _, b := range bl.balls {
+		if g.has(b) && !b.potted {
+			n++
+		}
+	}
+	return n
+}
+
+// inPocket reports whether the center of a ball at p went into a pocket. The
+// cushions only have gaps at the pockets, so being past them counts too.
+func (bl *billiards) inPocket(p Vec2) bool {
+	for _, pocket := range bl.pockets {
+		if p.To(pocket).Len() < bl.pocket {
+			return true
+		}
+	}
+	return p.X < bl.upperLeft.X || p.X > bl.lowerRight.X || p.Y < bl.upperLeft.Y || p.Y > bl.lowerRight.Y
+}
+
+// clampToCloth keeps the cue ball in hand on the table.
+func (bl *billiards) clampToCloth(p Vec2) Vec2 {
+	inset := bl.radius + bl.rails[0].radius
+	return Vec2{
+		clamp(p.X, bl.upperLeft.X+inset, bl.lowerRight.X-inset),
+		clamp(p.Y, bl.upperLeft.Y+inset, bl.lowerRight.Y-inset),
+	}
+}
+
+// canPlace reports whether the cue ball fits at p without touching a ball.
+func (bl *billiards) canPlace(p Vec2) bool {
+	for _, b := range bl.balls {
+		if b != bl.cue && b.circle.index >= 0 && p.To(b.circle.pos).Len() < bl.radius*2 {
+			return false
+		}
+	}
+	return true
+}
+
+func (bl *billiards) update(speed float64) {
+	cursor := cursorPosition()
+	switch bl.state {
+	case poolOver:
+		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+			bl.rack()
+		}
+	case poolPlacing:
+		p := bl.clampToCloth(cursor)
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && bl.canPlace(p) {
+			c := bl.cue.circle
+			c.pos = p
+			c.vel = Vec2{0, 0}
+			bl.cue.potted = false
+			bl.engine.addCircle(c)
+			bl.state = poolAiming
+		}
+	case poolAiming:
+		// pull back from the cue ball and let go to shoot, like the
+		// slingshot in the sandbox
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			bl.aiming = true
+		}
+		if bl.aiming && inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
+			bl.aiming = false
+			bl.shoot(cursor.To(bl.cue.circle.pos))
+		}
+	}
+
+	bl.engine.update(int(bl.width), int(bl.height), speed, 1.0)
+	for _, b := range bl.balls {
+		if b.circle.index >= 0 && bl.inPocket(b.circle.pos) {
+			bl.engine.removeCircle(b.circle)
+			b.potted = true
+			if bl.state == poolRolling {
+				bl.potted = append(bl.potted, b)
+			}
+		}
+	}
+
+	if bl.state == poolRolling {
+		vel := bl.engine.bodies.vel
+		for i := range vel {
+			if vel[i].Len() > poolRest {
+				return
+			}
+		}
+		for i := range vel {
+			vel[i] = Vec2{0, 0}
+		}
+		bl.endShot()
+	}
+}
+
+// shoot hits the cue ball along pull, faster the further it was pulled.
+func (bl *billiards) shoot(pull Vec2) {
+	speed := math.Min(pull.Len()*poolShotScale, poolMaxShot)
+	if speed < 0.5 {
+		return
+	}
+	c := bl.cue.circle
+	bl.engine.ApplyImpulseAt(c, c.pos, pull.Unit().Scaled(speed*c.area))
+	bl.onEight = bl.cleared(bl.player)
+	bl.firstHit = nil
+	bl.potted = bl.potted[:0]
+	bl.state = poolRolling
+}
+
+// legalFirst reports whether b was a ball the shooter was allowed to hit
+// first.
+func (bl *billiards) legalFirst(b *poolBall) bool {
+	if bl.onEight {
+		return b.number == 8
+	}
+	g := bl.groups[bl.player]
+	if g == groupOpen {
+		return b.number != 8
+	}
+	return g.has(b)
+}
+
+// endShot applies the rules once every ball has stopped, and passes the turn
+// when the shooter fouled or didn't pot one of their own balls.
+func (bl *billiards) endShot() {
+	p := bl.player
+	name := "Player " + strconv.Itoa(p+1)
+	other := "Player " + strconv.Itoa(2-p)
+	breaking := bl.breaking
+	bl.breaking = false
+
+	foul := ""
+	switch {
+	case bl.cue.potted:
+		foul = "scratch"
+	case bl.firstHit == nil:
+		foul = "no ball hit"
+	case !bl.legalFirst(bl.firstHit):
+		foul = "wrong ball hit first"
+	}
+
+	for _, b := range bl.potted {
+		if b.number != 8 {
+			continue
+		}
+		bl.state = poolOver
+		if foul == "" && bl.onEight {
+			bl.message = name + " wins! Enter to rack again"
+		} else {
+			reason := " potted the eight too early, "
+			if bl.onEight {
+				reason = " fouled on the eight, "
+			}
+			bl.message = name + reason + other + " wins! Enter to rack again"
+		}
+		return
+	}
+
+	// the table stays open after the break whatever went down, the groups
+	// are decided by the first ball potted after it
+	if foul == "" && !breaking && bl.groups[p] == groupOpen {
+		for _, b := range bl.potted {
+			if b != bl.cue {
+				g := groupSolids
+				if groupStripes.has(b) {
+					g = groupStripes
+				}
+				bl.groups[p] = g
+				bl.groups[1-p] = g.other()
+				break
+			}
+		}
+	}
+
+	keep := false
+	for _, b := range bl.potted {
+		own := bl.groups[p].has(b) || (bl.groups[p] == groupOpen && b != bl.cue)
+		keep = keep || (foul == "" && own)
+	}
+	if keep {
+		bl.message = name + " pots and shoots again"
+		bl.state = poolAiming
+		return
+	}
+	bl.player = 1 - p
+	if foul == "" {
+		bl.message = other + " to shoot"
+		bl.state = poolAiming
+		return
+	}
+	bl.message = name + " fouled (" + foul + "), " + other + " has ball in hand"
+	if bl.cue.circle.index >= 0 {
+		bl.engine.removeCircle(bl.cue.circle)
+	}
+	bl.state = poolPlacing
+}
+
+// predict follows the cue ball from its position along dir and returns where
+// it first touches a ball or a cushion, or drops into a pocket, and the ball
+// it hits if any.
+func (bl *billiards) predict(dir Vec2) (Vec2, *poolBall) {
+	origin := bl.cue.circle.pos
+	best := bl.lowerRight.To(bl.upperLeft).Len()
+	var hit *poolBall
+	// the gaps in the cushions are all inside the pockets, so this keeps the
+	// ghost ball on the table
+	for _, p := range bl.pockets {
+		if t, ok := rayCircle(origin, dir, p, bl.pocket); ok && t < best {
+			best = t
+		}
+	}
+	for _, b := range bl.balls {
+		if b == bl.cue || b.circle.index < 0 {
+			continue
+		}
+		if t, ok := rayCircle(origin, dir, b.circle.pos, bl.radius*2); ok && t < best {
+			best = t
+			hit = b
+		}
+	}
+	for _, c := range bl.rails {
+		a, b := c.segment(0)
+		if t, ok := rayCapsule(origin, dir, a, b, bl.radius+c.radius); ok && t < best {
+			best = t
+			hit = nil
+		}
+	}
+	return origin.Add(dir.Scaled(best)), hit
+}
+
+// rayCircle returns how far along the unit direction dir from origin the ray
+// enters a circle.
+func rayCircle(origin, dir, center Vec2, radius float64) (float64, bool) {
+	m := center.To(origin)
+	b := m.Dot(dir)
+	c := m.Dot(m) - radius*radius
+	if c > 0 && b > 0 {
+		return 0, false
+	}
+	disc := b*b - c
+	if disc < 0 {
+		return 0, false
+	}
+	return math.Max(-b-math.Sqrt(disc), 0), true
+}
+
+// rayCapsule returns how far along the unit direction dir from origin the ray
+// enters the capsule around the segment from a to b.
+func rayCapsule(origin, dir, a, b Vec2, radius float64) (float64, bool) {
+	best := math.MaxFloat64
+	for _, end := range [2]Vec2{a, b} {
+		if t, ok := rayCircle(origin, dir, end, radius); ok {
+			best = math.Min(best, t)
+		}
+	}
+	ab := a.To(b)
+	n := ab.Normal().Unit()
+	for _, side := range [2]float64{1, -1} {
+		ns := n.Scaled(side)
+		face := a.Add(ns.Scaled(radius))
+		d := dir.Dot(ns)
+		if d >= 0 {
+			continue
+		}
+		t := face.Sub(origin).Dot(ns) / d
+		if t < 0 {
+			continue
+		}
+		u := a.To(origin.Add(dir.Scaled(t))).Dot(ab) / ab.Dot(ab)
+		if u >= 0 && u <= 1 {
+			best = math.Min(best, t)
+		}
+	}
+	return best, best < math.MaxFloat64
+}
+
+func (bl *billiards) draw(screen *ebiten.Image) {
+	cursor := cursorPosition()
+	ul := bl.upperLeft
+	lr := bl.lowerRight
+	size := ul.To(lr)
+	border := bl.radius * 3
+	ebitenutil.DrawRect(screen, ul.X-border, ul.Y-border, size.X+border*2, size.Y+border*2, colorful.Hcl(60, 0.3, 0.3).Clamped())
+	ebitenutil.DrawRect(screen, ul.X, ul.Y, size.X, size.Y, colorful.Hcl(150, 0.45, 0.4).Clamped())
+	for _, c := range bl.rails {
+		c.Draw(screen, false)
+	}
+	for _, p := range bl.pockets {
+		drawCircleOutline(screen, p, bl.pocket/2, bl.pocket, colorful.Hsl(0, 0, 0.05))
+	}
+
+	for _, b := range bl.balls {
+		if b.circle.index >= 0 {
+			bl.drawBall(screen, b, b.circle.pos)
+		}
+	}
+
+	switch bl.state {
+	case poolPlacing:
+		p := bl.clampToCloth(cursor)
+		bl.drawBall(screen, bl.cue, p)
+		if !bl.canPlace(p) {
+			drawCircleOutline(screen, p, bl.radius, 2, colorful.Hcl(20, 0.8, 0.6).Clamped())
+		}
+	case poolAiming:
+		if bl.aiming {
+			bl.drawAim(screen, cursor)
+		}
+	}
+
+	var msg strings.Builder
+	for p := 0; p < 2; p++ {
+		if p == bl.player && bl.state != poolOver {
+			msg.WriteString("> ")
+		} else {
+			msg.WriteString("  ")
+		}
+		msg.WriteString("Player ")
+		msg.WriteString(strconv.Itoa(p + 1))
+		msg.WriteString(": ")
+		g := bl.groups[p]
+		msg.WriteString(g.String())
+		if g != groupOpen {
+			msg.WriteString(", ")
+			msg.WriteString(strconv.Itoa(7 - bl.left(g)))
+			msg.WriteString(" potted")
+		}
+		msg.WriteString("\n")
+	}
+	msg.WriteString("\n")
+	msg.WriteString(bl.message)
+	msg.WriteString("\n\nDrag back from the cue ball and let go to shoot")
+	msg.WriteString("\nBall in hand: click to place the cue ball")
+	msg.WriteString("\nF1: back to the sandbox")
+	ebitenutil.DebugPrintAt(screen, msg.String(), 10, 10)
+}
+
+// drawBall draws b at p, with a white band around stripes and its number in
+// the middle.
+func (bl *billiards) drawBall(screen *ebiten.Image, b *poolBall, p Vec2) {
+	r := bl.radius
+	if b.number > 8 {
+		drawCircleOutline(screen, p, r/2, r, poolColors[0])
+		drawCircleOutline(screen, p, r*0.35, r*0.7, b.color())
+	} else {
+		drawCircleOutline(screen, p, r/2, r, b.color())
+	}
+	if b.number > 0 {
+		label := strconv.Itoa(b.number)
+		ebitenutil.DebugPrintAt(screen, label, int(p.X)-3*len(label), int(p.Y)-8)
+	}
+}
+
+// drawAim draws the cue pulled back to cursor, the path of the cue ball up to
+// what it hits first, a ghost ball where it touches, and which way a struck
+// ball will go.
+func (bl *billiards) drawAim(screen *ebiten.Image, cursor Vec2) {
+	from := bl.cue.circle.pos
+	pull := cursor.To(from)
+	if pull.Len() == 0 {
+		return
+	}
+	dir := pull.Unit()
+	white := colorful.Hsl(0, 0, 0.9)
+	cue := colorful.Hcl(60, 0.4, 0.7).Clamped()
+	drawLine(from.Sub(dir.Scaled(bl.radius*1.5)), cursor.Sub(dir.Scaled(bl.radius*1.5)), 4, screen, cue, 1.0)
+
+	ghost, hit := bl.predict(dir)
+	drawLine(from, ghost, 1, screen, white, 0.6)
+	drawCircleOutline(screen, ghost, bl.radius, 1, white)
+	if hit != nil {
+		away := ghost.To(hit.circle.pos).Unit()
+		p := hit.circle.pos
+		drawLine(p, p.Add(away.Scaled(bl.radius*6)), 1, screen, hit.color(), 0.8)
+	}
+
+	power := math.Min(pull.Len()*poolShotScale, poolMaxShot) / poolMaxShot
+	ebitenutil.DebugPrintAt(screen, strconv.Itoa(int(power*100))+"%", int(cursor.X)+12, int(cursor.Y)+12)
+}
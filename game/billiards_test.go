@@ -0,0 +1,57 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPredictStopsAtPockets(t *testing.T) {
+	bl := newBilliards(800, 600, nil)
+	for _, b := range bl.balls {
+		if b != bl.cue {
+			bl.engine.removeCircle(b.circle)
+		}
+	}
+	// every way out of the table goes through a pocket, so whichever way the
+	// cue ball is sent it stops at most a pocket's radius past the cloth
+	ul := bl.upperLeft.Sub(Vec2{bl.pocket, bl.pocket})
+	lr := bl.lowerRight.Add(Vec2{bl.pocket, bl.pocket})
+	const directions = 360
+	for k := 0; k < directions; k++ {
+		angle := twoPi * float64(k) / directions
+		ghost, _ := bl.predict(Vec2{math.Cos(angle), math.Sin(angle)})
+		if ghost.X < ul.X || ghost.X > lr.X || ghost.Y < ul.Y || ghost.Y > lr.Y {
+			t.Errorf("aiming at %.0f degrees stopped off the table at %v", angle*180/math.Pi, ghost)
+		}
+	}
+}
+
+func TestTableStaysOpenAfterBreak(t *testing.T) {
+	bl := newBilliards(800, 600, nil)
+	var solid *poolBall
+	for _, b := range bl.balls {
+		if groupSolids.has(b) {
+			solid = b
+			break
+		}
+	}
+	bl.shoot(Vec2{100, 0})
+	bl.firstHit = solid
+	bl.potted = append(bl.potted, solid)
+	bl.endShot()
+	if bl.groups != [2]poolGroup{} {
+		t.Errorf("groups %v after the break, want the table open", bl.groups)
+	}
+	if bl.player != 0 || bl.state != poolAiming {
+		t.Errorf("player %v in state %v, want the breaker to shoot again", bl.player, bl.state)
+	}
+
+	// the next ball potted decides the groups
+	bl.shoot(Vec2{100, 0})
+	bl.firstHit = solid
+	bl.potted = append(bl.potted, solid)
+	bl.endShot()
+	if want := [2]poolGroup{groupSolids, groupStripes}; bl.groups != want {
+		t.Errorf("groups %v, want %v", bl.groups, want)
+	}
+}
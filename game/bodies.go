@@ -0,0 +1,80 @@
+package game
+
+// bodies stores the simulation state of every circle in the engine as a
+// struct of arrays. Index i in each slice belongs to the same body, and
+// Engine.circles[i] is the Circle that is drawn for it.
+//
+// Keeping the hot data in contiguous slices means the stepping loops walk
+// memory linearly instead of chasing a pointer per circle, and none of the
+// slices need to grow once the population is stable, so a step doesn't
+// allocate.
+type bodies struct {
+	pos     []Vec2
+	prevPos []Vec2 // position at the start of the current substep
+	vel     []Vec2
+	impulse []Vec2 // velocity change applied once on the next substep
+	radius  []float64
+	invMass []float64
+	speed   []float64 // speed at the end of the previous frame
+	energy  []float64 // collision energy accumulated over the frame
+	drag    []Drag
+	fluid   []bool // simulated as an SPH particle
+	charge  []float64
+}
+
+func (b *bodies) len() int {
+	return len(b.pos)
+}
+
+// add appends a body for the circle and returns its index.
+func (b *bodies) add(c *Circle) int {
+	b.pos = append(b.pos, c.pos)
+	b.prevPos = append(b.prevPos, c.pos)
+	b.vel = append(b.vel, c.vel)
+	b.impulse = append(b.impulse, Vec2{0, 0})
+	b.radius = append(b.radius, c.radius)
+	b.invMass = append(b.invMass, 1.0/c.area)
+	b.speed = append(b.speed, c.vel.Len())
+	b.energy = append(b.energy, 0)
+	b.drag = append(b.drag, c.drag)
+	b.fluid = append(b.fluid, c.fluid)
+	b.charge = append(b.charge, c.charge)
+	return len(b.pos) - 1
+}
+
+// swapRemove removes body i by moving the last body into its place.
+func (b *bodies) swapRemove(i int) {
+	last := len(b.pos) - 1
+	b.pos[i] = b.pos[last]
+	b.prevPos[i] = b.prevPos[last]
+	b.vel[i] = b.vel[last]
+	b.impulse[i] = b.impulse[last]
+	b.radius[i] = b.radius[last]
+	b.invMass[i] = b.invMass[last]
+	b.speed[i] = b.speed[last]
+	b.energy[i] = b.energy[last]
+	b.drag[i] = b.drag[last]
+	b.fluid[i] = b.fluid[last]
+	b.charge[i] = b.charge[last]
+
+	b.pos = b.pos[:last]
+	b.prevPos = b.prevPos[:last]
+	b.vel = b.vel[:last]
+	b.impulse = b.impulse[:last]
+	b.radius = b.radius[:last]
+	b.invMass = b.invMass[:last]
+	b.speed = b.speed[:last]
+	b.energy = b.energy[:last]
+	b.drag = b.drag[:last]
+	b.fluid = b.fluid[:last]
+	b.charge = b.charge[:last]
+}
+
+// resizeVec2 returns s with length n, reusing its backing array when it is
+// large enough.
+func resizeVec2(s []Vec2, n int) []Vec2 {
+	if cap(s) < n {
+		return make([]Vec2, n)
+	}
+	return s[:n]
+}
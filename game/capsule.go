@@ -5,14 +5,18 @@ import (
 	"github.com/lucasb-eyer/go-colorful"
 )
 
-// NewCapsule creates a new line from (x1, y1) to (x2, y2)
+// NewCapsule creates a new line from (x1, y1) to (x2, y2). A nil shader
+// leaves it without an image, like NewCircle.
 func NewCapsule(start, end Vec2, r float64, shader *ebiten.Shader) *Capsule {
-	width := int(r)*2 + 3
-	height := width
+	var img *ebiten.Image
+	if shader != nil {
+		width := int(r)*2 + 3
+		height := width
 
-	img := ebiten.NewImage(width, height)
+		img = ebiten.NewImage(width, height)
 
-	drawCircleToImage(img, shader)
+		drawCircleToImage(img, shader)
+	}
 	return &Capsule{
 		start:  start,
 		end:    end,
@@ -23,10 +27,13 @@ func NewCapsule(start, end Vec2, r float64, shader *ebiten.Shader) *Capsule {
 
 // Capsule represents a line that can collide with circles
 type Capsule struct {
-	start  Vec2
-	end    Vec2
-	radius float64
-	image  *ebiten.Image
+	start        Vec2
+	end          Vec2
+	radius       float64
+	surfaceSpeed float64 // of the belt around the capsule, clockwise is positive
+	oneWay       Vec2    // normal of the only side that blocks circles, zero for both
+	motion       motion  // set while a path moves the capsule
+	image        *ebiten.Image
 }
 
 // Draw the line to the screen.
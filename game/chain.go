@@ -0,0 +1,255 @@
+package game
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// NewChain creates a collider of capsules joined end to end through points.
+// A closed chain also joins the last point back to the first.
+func NewChain(points []Vec2, radius float64, closed bool) *Chain {
+	c := &Chain{
+		points: points,
+		radius: radius,
+		closed: closed,
+	}
+	c.updateBounds()
+	return c
+}
+
+// Chain is a polyline with thickness that circles collide with, for ramps,
+// funnels and tracks.
+//
+// Each joint is shared by two segments. Testing them one at a time, a circle
+// rolling over a joint would also hit the end of the next segment and snag on
+// it, so the segments use their neighbours as ghost vertices: a segment only
+// takes a contact at its start if the previous segment doesn't already cover
+// it, and never at its end when there is a next segment to take it.
+type Chain struct {
+	points []Vec2
+	radius float64
+	closed bool
+
+	// bounding box of the points, to skip circles that are nowhere near
+	low  Vec2
+	high Vec2
+}
+
+// chainSelection is a point of a chain being dragged with the mouse.
+type chainSelection struct {
+	chain *Chain
+	point int
+}
+
+// segments returns the number of segments in the chain.
+func (c *Chain) segments() int {
+	if c.closed && len(c.points) > 2 {
+		return len(c.points)
+	}
+	return len(c.points) - 1
+}
+
+// segment returns the start and end of segment k.
+func (c *Chain) segment(k int) (Vec2, Vec2) {
+	return c.points[k], c.points[(k+1)%len(c.points)]
+}
+
+func (c *Chain) updateBounds() {
+	if len(c.points) == 0 {
+		return
+	}
+	c.low = c.points[0]
+	c.high = c.points[0]
+	for _, p := range c.points {
+		c.low = Vec2{math.Min(c.low.X, p.X), math.Min(c.low.Y, p.Y)}
+		c.high = Vec2{math.Max(c.high.X, p.X), math.Max(c.high.Y, p.Y)}
+	}
+}
+
+// closestOnSegment returns how far along the segment from a to b the point
+// closest to p is, from 0 at a to 1 at b.
+func closestOnSegment(a, b, p Vec2) float64 {
+	ab := a.To(b)
+	length2 := ab.Dot(ab)
+	if length2 == 0 {
+		return 0
+	}
+	return clamp(a.To(p).Dot(ab)/length2, 0, 1)
+}
+
+func (e *Engine) addChain(c *Chain) {
+	e.chains = append(e.chains, c)
+}
+
+// removeChain takes c out of the engine. It returns false if c wasn't in it.
+func (e *Engine) removeChain(c *Chain) bool {
+	for i, chain := range e.chains {
+		if chain != c {
+			continue
+		}
+		e.chains = append(e.chains[:i], e.chains[i+1:]...)
+		if e.selectedChain.chain == c {
+			e.deselectChainPoint()
+		}
+		return true
+	}
+	return false
+}
+
+// newRamp returns a demo chain, a wavy ramp along the bottom left of a world
+// of the given size.
+func newRamp(w, h float64) *Chain {
+	var ramp []Vec2
+	for k := 0; k <= 8; k++ {
+		x := w * (0.05 + 0.3*float64(k)/8)
+		ramp = append(ramp, Vec2{x, h*0.7 + float64(k)*h*0.02 + math.Sin(float64(k))*h*0.02})
+	}
+	return NewChain(ramp, 4, false)
+}
+
+// resolveChain pushes body i out of every segment of the chain it overlaps.
+func (e *Engine) resolveChain(c *Chain, i int) {
+	r := e.bodies.radius[i]
+	reach := c.radius + r
+	p := e.bodies.pos[i]
+	if p.X < c.low.X-reach || p.X > c.high.X+reach || p.Y < c.low.Y-reach || p.Y > c.high.Y+reach {
+		return
+	}
+	n := c.segments()
+	for k := 0; k < n; k++ {
+		p = e.bodies.pos[i]
+		a, b := c.segment(k)
+		t := closestOnSegment(a, b, p)
+		hasPrev := c.closed || k > 0
+		hasNext := c.closed || k < n-1
+		if t >= 1 && hasNext {
+			// the next segment owns the joint
+			continue
+		}
+		if t <= 0 && hasPrev {
+			prev, _ := c.segment((k - 1 + n) % n)
+			if closestOnSegment(prev, a, p) < 1 {
+				// the previous segment's face is closer, it handles this
+				continue
+			}
+		}
+		closest := a.Add(a.To(b).Scaled(t))
+		v := closest.To(p)
+		dist := v.Len()
+		if dist >= reach {
+			continue
+		}
+		var normal Vec2
+		if dist == 0 {
+			normal = a.To(b).Normal().Unit()
+		} else {
+			normal = v.Scaled(1.0 / dist)
+		}
+		e.pushFromWall(i, normal, reach-dist, 1)
+	}
+}
+
+// selectChainPointAt starts dragging the chain point under pos.
+func (e *Engine) selectChainPointAt(pos Vec2) bool {
+	for _, c := range e.chains {
+		grab := math.Max(c.radius, 8)
+		for k, p := range c.points {
+			if p.To(pos).Len() < grab {
+				e.selectedChain = chainSelection{c, k}
+				return true
+			}
+		}
+	}
+	e.selectedChain = chainSelection{}
+	return false
+}
+
+func (e *Engine) moveSelectedChainPointTo(pos Vec2) bool {
+	c := e.selectedChain.chain
+	if c == nil {
+		return false
+	}
+	c.points[e.selectedChain.point] = pos
+	c.updateBounds()
+	return true
+}
+
+func (e *Engine) deselectChainPoint() {
+	e.selectedChain = chainSelection{}
+}
+
+// insertChainPointAt splits the chain segment under pos with a new point at
+// pos, and starts dragging it.
+func (e *Engine) insertChainPointAt(pos Vec2) bool {
+	for _, c := range e.chains {
+		grab := math.Max(c.radius, 8)
+		for k := 0; k < c.segments(); k++ {
+			a, b := c.segment(k)
+			t := closestOnSegment(a, b, pos)
+			if a.Add(a.To(b).Scaled(t)).To(pos).Len() < grab {
+				c.points = append(c.points, Vec2{})
+				copy(c.points[k+2:], c.points[k+1:])
+				c.points[k+1] = pos
+				c.updateBounds()
+				e.selectedChain = chainSelection{c, k + 1}
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeChainPointAt removes the chain point under pos, as long as an open
+// chain keeps at least one segment and a loop stays a triangle.
+func (e *Engine) removeChainPointAt(pos Vec2) bool {
+	for _, c := range e.chains {
+		least := 2
+		if c.closed {
+			least = 3
+		}
+		if len(c.points) <= least {
+			continue
+		}
+		grab := math.Max(c.radius, 8)
+		for k, p := range c.points {
+			if p.To(pos).Len() >= grab {
+				continue
+			}
+			c.points = append(c.points[:k], c.points[k+1:]...)
+			c.updateBounds()
+			// keep a point being dragged pointing at the same point
+			if e.selectedChain.chain == c {
+				switch {
+				case k == e.selectedChain.point:
+					e.deselectChainPoint()
+				case k < e.selectedChain.point:
+					e.selectedChain.point--
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Draw the chain like a row of capsules, with handles on the points when
+// editing.
+func (c *Chain) Draw(screen *ebiten.Image, showPoints bool) {
+	clr := colorful.Hsl(0, 0, 0.5)
+	for k := 0; k < c.segments(); k++ {
+		a, b := c.segment(k)
+		drawLine(a, b, c.radius*2, screen, clr, 1.0)
+	}
+	// round off the joints
+	for _, p := range c.points {
+		drawCircleOutline(screen, p, c.radius/2, c.radius, clr)
+	}
+	if showPoints {
+		handle := colorful.Hsl(200, 0.6, 0.6)
+		for _, p := range c.points {
+			drawCircleOutline(screen, p, math.Max(c.radius, 8), 1.5, handle)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package game
+
+import "testing"
+
+func TestRemoveChainPointWhileDragging(t *testing.T) {
+	e := NewEngine(800, 600, nil, nil, nil)
+	c := NewChain([]Vec2{{100, 100}, {200, 100}, {300, 100}, {400, 100}}, 4, false)
+	e.addChain(c)
+
+	// dragging the third point, remove the first
+	e.selectedChain = chainSelection{c, 2}
+	if !e.removeChainPointAt(Vec2{100, 100}) {
+		t.Fatal("point not removed")
+	}
+	if e.selectedChain.point != 1 || c.points[1] != (Vec2{300, 100}) {
+		t.Errorf("selection moved to point %v", e.selectedChain.point)
+	}
+
+	// removing the dragged point itself drops the selection
+	if !e.removeChainPointAt(Vec2{300, 100}) {
+		t.Fatal("point not removed")
+	}
+	if e.selectedChain.chain != nil {
+		t.Errorf("selection kept after its point was removed")
+	}
+	if e.moveSelectedChainPointTo(Vec2{0, 0}) {
+		t.Errorf("moved a point with nothing selected")
+	}
+
+	// an open chain keeps one segment
+	if e.removeChainPointAt(Vec2{200, 100}) || len(c.points) != 2 {
+		t.Errorf("removed a point from a single segment")
+	}
+}
+
+func TestRemoveChainPointKeepsLoopsClosed(t *testing.T) {
+	e := NewEngine(800, 600, nil, nil, nil)
+	c := NewChain([]Vec2{{100, 100}, {200, 100}, {150, 200}}, 4, true)
+	e.addChain(c)
+	if e.removeChainPointAt(Vec2{100, 100}) || len(c.points) != 3 {
+		t.Errorf("a closed triangle lost a point")
+	}
+}
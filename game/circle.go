@@ -61,15 +61,20 @@ func drawCircleToImage(img *ebiten.Image, shader *ebiten.Shader) {
 	img.DrawRectShader(w, h, shader, op)
 }
 
-// NewCircle creates a new circle at position x,y with radius r
+// NewCircle creates a new circle at position x,y with radius r. A nil
+// shader leaves it without an image, for circles that are simulated but
+// never drawn.
 func NewCircle(x, y, r float64, shader *ebiten.Shader) *Circle {
 
-	var width = int(r)*2 + 3
-	var height = width
+	var img *ebiten.Image
+	if shader != nil {
+		var width = int(r)*2 + 3
+		var height = width
 
-	img := ebiten.NewImage(width, height)
+		img = ebiten.NewImage(width, height)
 
-	drawCircleToImage(img, shader)
+		drawCircleToImage(img, shader)
+	}
 
 	// mod controls the accumulation of activity based on speed
 	maxMod := remap(r, 5, 70, 5, 2)
@@ -82,6 +87,7 @@ func NewCircle(x, y, r float64, shader *ebiten.Shader) *Circle {
 
 	return &Circle{
 		selected:  false,
+		shader:    shader,
 		pos:       Vec2{x, y},
 		radius:    r,
 		area:      math.Pi * r * r,
@@ -104,24 +110,54 @@ func randomCircleColor() colorful.Color {
 }
 
 // Circle represents a circle
+//
+// Once a circle is added to an Engine, its simulation state lives in the
+// engine's body storage at index. The pos, prevPos and vel fields are copies
+// refreshed after every update so the circle can draw itself.
 type Circle struct {
 	selected bool
-	id       int
+	hidden   bool // drawn by the soft body or fluid it belongs to instead
+	merging  bool
+	index    int
 	pos      Vec2
 	prevPos  Vec2
 	vel      Vec2
-	acc      Vec2
 	radius   float64
 	area     float64
 	speed    float64
+	drag     Drag // zero value follows the engine's drag
+	fluid    bool // simulated as an SPH particle
+	charge   float64
 
 	activity  float64
 	maxMod    float64
 	dimRate   float64
 	maxCharge float64
 
-	color colorful.Color
-	image *ebiten.Image
+	color  colorful.Color
+	image  *ebiten.Image
+	stale  bool // image was drawn for an older radius
+	shader *ebiten.Shader
+}
+
+// setRadius changes the size of the circle. It is called mid update, so the
+// image is only marked stale here and redrawn the next time it is drawn.
+func (c *Circle) setRadius(r float64) {
+	c.radius = r
+	c.area = math.Pi * r * r
+	c.stale = true
+}
+
+// sprite returns the image of the circle, redrawing it first if the radius
+// changed since it was last drawn.
+func (c *Circle) sprite() *ebiten.Image {
+	if c.stale && c.shader != nil {
+		width := int(c.radius)*2 + 3
+		c.image = ebiten.NewImage(width, width)
+		drawCircleToImage(c.image, c.shader)
+		c.stale = false
+	}
+	return c.image
 }
 
 func (c *Circle) postUpdate() {
@@ -137,23 +173,30 @@ func (c *Circle) addCollisionEnergy(energy float64) {
 	c.activity += mod
 }
 
+// activityColor returns the color of the circle, with chroma and lightness
+// set by its activity.
+func (c Circle) activityColor() colorful.Color {
+	if c.selected {
+		c.activity = math.Max(c.activity, 1.0)
+	}
+	hue, _, _ := c.color.Hcl()
+	chroma := remap(math.Min(c.activity, 1), 0, 1, 0, 1)
+	lightness := remap(math.Min(c.activity, 1), 0, 1, 0.45, 0.9)
+	return colorful.Hcl(hue, chroma, lightness)
+}
+
 // Draw the circle to the screen.
-func (c Circle) Draw(screen *ebiten.Image) {
+func (c *Circle) Draw(screen *ebiten.Image) {
 	op := &ebiten.DrawImageOptions{}
+	img := c.sprite()
 
 	// set chroma and lightness based on speed
+	clr := c.activityColor()
+	r := clr.R
+	g := clr.G
+	b := clr.B
 	if c.selected {
-		c.activity = math.Max(c.activity, 1.0)
-	}
-	hue, chroma, lightness := c.color.Hcl()
-	chroma = remap(math.Min(c.activity, 1), 0, 1, 0, 1)
-	lightness = remap(math.Min(c.activity, 1), 0, 1, 0.45, 0.9)
-	c.color = colorful.Hcl(hue, chroma, lightness)
-	r := c.color.R
-	g := c.color.G
-	b := c.color.B
-	if c.selected {
-		h, s, v := c.color.Hsv()
+		h, s, v := clr.Hsv()
 		col := colorful.Hsv(h, s, math.Min(v+0.25, 1))
 		r = col.R
 		g = col.G
@@ -165,8 +208,8 @@ func (c Circle) Draw(screen *ebiten.Image) {
 		a := remap(clamp(c.speed, 10, 75), 10, 75, 0, 0.95)
 		op.GeoM.Translate(c.prevPos.X-c.radius, c.prevPos.Y-c.radius)
 		op.ColorM.Scale(r, g, b, a)
-		screen.DrawImage(c.image, op)
-		drawLine(c.pos, c.prevPos, c.radius*1.9, screen, c.color, a)
+		screen.DrawImage(img, op)
+		drawLine(c.pos, c.prevPos, c.radius*1.9, screen, clr, a)
 	}
 
 	// Draw the circle
@@ -174,6 +217,6 @@ func (c Circle) Draw(screen *ebiten.Image) {
 	op.ColorM.Reset()
 	op.ColorM.Scale(r, g, b, 1)
 	op.GeoM.Translate(c.pos.X-c.radius, c.pos.Y-c.radius)
-	screen.DrawImage(c.image, op)
+	screen.DrawImage(img, op)
 
 }
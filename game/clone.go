@@ -0,0 +1,345 @@
+package game
+
+// Clone returns a cheap copy of the world that can be stepped without
+// touching e, for predictions and what-if experiments. Bodies and everything
+// that moves or is moved by the simulation are copied. Chains, curves, arenas
+// and portals don't change while stepping, and paths only depend on the time
+// they are asked about, so the copy shares them and editing them changes both
+// worlds. The copy has no touch callbacks.
+func (e *Engine) Clone() *Engine {
+	c := &Engine{}
+	e.cloneInto(c)
+	return c
+}
+
+// cloneInto makes c a copy of e like Clone does, reusing the storage c had
+// before, circles and colliders included, so a scratch world can be made over
+// every frame without allocating a new one. c keeps its touch callbacks.
+func (e *Engine) cloneInto(c *Engine) {
+	old := *c
+	*c = *e
+	c.onTouch = old.onTouch
+	c.onTouchCapsule = old.onTouchCapsule
+	if old.accel != nil {
+		// already bound to c
+		c.accel = old.accel
+	} else {
+		c.accel = c.accelerations
+	}
+	c.integrator = copyIntegrator(old.integrator, e.integrator)
+
+	b := &c.bodies
+	b.pos = append(old.bodies.pos[:0], e.bodies.pos...)
+	b.prevPos = append(old.bodies.prevPos[:0], e.bodies.prevPos...)
+	b.vel = append(old.bodies.vel[:0], e.bodies.vel...)
+	b.impulse = append(old.bodies.impulse[:0], e.bodies.impulse...)
+	b.radius = append(old.bodies.radius[:0], e.bodies.radius...)
+	b.invMass = append(old.bodies.invMass[:0], e.bodies.invMass...)
+	b.speed = append(old.bodies.speed[:0], e.bodies.speed...)
+	b.energy = append(old.bodies.energy[:0], e.bodies.energy...)
+	b.drag = append(old.bodies.drag[:0], e.bodies.drag...)
+	b.fluid = append(old.bodies.fluid[:0], e.bodies.fluid...)
+	b.charge = append(old.bodies.charge[:0], e.bodies.charge...)
+	c.order = append(old.order[:0], e.order...)
+
+	// copies line up with the originals by index, circles also know theirs
+	spareCircles := old.circles[:cap(old.circles)]
+	c.circles = spareCircles[:0]
+	for i, circle := range e.circles {
+		var copied *Circle
+		if i < len(spareCircles) && spareCircles[i] != nil {
+			copied = spareCircles[i]
+		} else {
+			copied = new(Circle)
+		}
+		*copied = *circle
+		c.circles = append(c.circles, copied)
+	}
+	copyOf := func(circle *Circle) *Circle {
+		if circle == nil || circle.index < 0 {
+			return nil
+		}
+		return c.circles[circle.index]
+	}
+	c.selectedCircle.pointer = copyOf(e.selectedCircle.pointer)
+
+	spareCapsules := old.capsules[:cap(old.capsules)]
+	c.capsules = spareCapsules[:0]
+	for i, capsule := range e.capsules {
+		var copied *Capsule
+		if i < len(spareCapsules) && spareCapsules[i] != nil {
+			copied = spareCapsules[i]
+		} else {
+			copied = new(Capsule)
+		}
+		*copied = *capsule
+		c.capsules = append(c.capsules, copied)
+	}
+	spareRects := old.collisionRects[:cap(old.collisionRects)]
+	c.collisionRects = spareRects[:0]
+	for i, r := range e.collisionRects {
+		var copied *collisionRect
+		if i < len(spareRects) && spareRects[i] != nil {
+			copied = spareRects[i]
+		} else {
+			copied = new(collisionRect)
+		}
+		*copied = *r
+		c.collisionRects = append(c.collisionRects, copied)
+	}
+	spareKinematics := old.kinematics[:cap(old.kinematics)]
+	c.kinematics = spareKinematics[:0]
+	for i, k := range e.kinematics {
+		var copied *Kinematic
+		if i < len(spareKinematics) && spareKinematics[i] != nil {
+			copied = spareKinematics[i]
+		} else {
+			copied = new(Kinematic)
+		}
+		*copied = *k
+		for j, capsule := range e.capsules {
+			if capsule == k.capsule {
+				copied.capsule = c.capsules[j]
+			}
+		}
+		for j, r := range e.collisionRects {
+			if r == k.rect {
+				copied.rect = c.collisionRects[j]
+			}
+		}
+		c.kinematics = append(c.kinematics, copied)
+	}
+
+	c.springs = append(old.springs[:0], e.springs...)
+	for k := range c.springs {
+		c.springs[k].a = copyOf(c.springs[k].a)
+		c.springs[k].b = copyOf(c.springs[k].b)
+	}
+	spareSoftBodies := old.softBodies[:cap(old.softBodies)]
+	c.softBodies = spareSoftBodies[:0]
+	for i, sb := range e.softBodies {
+		var copied *SoftBody
+		if i < len(spareSoftBodies) && spareSoftBodies[i] != nil {
+			copied = spareSoftBodies[i]
+		} else {
+			copied = new(SoftBody)
+		}
+		members := copied.members[:0]
+		*copied = SoftBody{restArea: sb.restArea, pressure: sb.pressure, color: sb.color}
+		for _, m := range sb.members {
+			members = append(members, copyOf(m))
+		}
+		copied.members = members
+		c.softBodies = append(c.softBodies, copied)
+	}
+
+	// the solvers keep their settings, scratch space isn't shared
+	f := old.fluid
+	if f == nil || f == e.fluid {
+		f = new(fluid)
+	}
+	pairs, density, pressure := f.pairs[:0], f.density[:0], f.pressure[:0]
+	*f = *e.fluid
+	f.pairs, f.density, f.pressure = pairs, density, pressure
+	c.fluid = f
+	nbody := old.nbody
+	if nbody == nil || nbody == e.nbody {
+		nbody = new(barnesHut)
+	}
+	nodes, stack := nbody.nodes[:0], nbody.stack[:0]
+	*nbody = *e.nbody
+	nbody.nodes, nbody.stack = nodes, stack
+	c.nbody = nbody
+	q := old.coulomb
+	if q == nil || q == e.coulomb {
+		q = new(coulomb)
+	}
+	chargedPairs := q.pairs[:0]
+	*q = *e.coulomb
+	q.pairs = chargedPairs
+	c.coulomb = q
+
+	c.merged = old.merged[:0]
+	c.queried = old.queried[:0]
+	c.collidingPairs = old.collidingPairs[:0]
+	c.collidingCapsules = old.collidingCapsules[:0]
+	c.walls = old.walls[:0]
+}
+
+// Snapshot is a saved copy of a world. It shares nothing with the engine it
+// came from, so it can be restored any number of times. It also remembers
+// which circles, colliders and soft bodies were in the world, so restoring
+// puts their state back into the same objects.
+//
+// Scratch space that every substep rewrites before reading, such as the
+// integrators' trial state and the stack used to walk the Barnes-Hut tree,
+// isn't saved. Verlet's previous positions and the last tree built are.
+type Snapshot struct {
+	world      *Engine
+	circles    []*Circle
+	capsules   []*Capsule
+	rects      []*collisionRect
+	kinematics []*Kinematic
+	chains     []*Chain
+	curves     []*Curve
+	arenas     []*Arena
+	portals    []*Portal
+	softBodies []*SoftBody
+}
+
+// Snapshot saves the whole state of the world.
+func (e *Engine) Snapshot() *Snapshot {
+	return &Snapshot{
+		world:      e.deepClone(),
+		circles:    append([]*Circle(nil), e.circles...),
+		capsules:   append([]*Capsule(nil), e.capsules...),
+		rects:      append([]*collisionRect(nil), e.collisionRects...),
+		kinematics: append([]*Kinematic(nil), e.kinematics...),
+		chains:     append([]*Chain(nil), e.chains...),
+		curves:     append([]*Curve(nil), e.curves...),
+		arenas:     append([]*Arena(nil), e.arenas...),
+		portals:    append([]*Portal(nil), e.portals...),
+		softBodies: append([]*SoftBody(nil), e.softBodies...),
+	}
+}
+
+// Restore puts the world back the way it was when s, which must have come
+// from e, was taken. Objects that were in the world then get their state
+// back in place, so pointers to them held outside the engine stay good.
+// Circles added since are left out, with an index of -1 like any removed
+// circle. The touch callbacks are kept.
+func (e *Engine) Restore(s *Snapshot) {
+	saved := make(map[*Circle]bool, len(s.circles))
+	for _, c := range s.circles {
+		saved[c] = true
+	}
+	for _, c := range e.circles {
+		if !saved[c] {
+			c.index = -1
+		}
+	}
+
+	onTouch := e.onTouch
+	onTouchCapsule := e.onTouchCapsule
+	w := s.world.deepClone()
+
+	// copy the restored state into the original objects, then point
+	// everything that refers to the copies at the originals instead
+	circles := make(map[*Circle]*Circle, len(s.circles))
+	for i, c := range s.circles {
+		*c = *w.circles[i]
+		circles[w.circles[i]] = c
+		w.circles[i] = c
+	}
+	w.selectedCircle.pointer = circles[w.selectedCircle.pointer]
+	for k := range w.springs {
+		w.springs[k].a = circles[w.springs[k].a]
+		w.springs[k].b = circles[w.springs[k].b]
+	}
+	for i, sb := range s.softBodies {
+		*sb = *w.softBodies[i]
+		for k, m := range sb.members {
+			sb.members[k] = circles[m]
+		}
+		w.softBodies[i] = sb
+	}
+
+	capsules := make(map[*Capsule]*Capsule, len(s.capsules))
+	for i, c := range s.capsules {
+		*c = *w.capsules[i]
+		capsules[w.capsules[i]] = c
+		w.capsules[i] = c
+	}
+	rects := make(map[*collisionRect]*collisionRect, len(s.rects))
+	for i, r := range s.rects {
+		*r = *w.collisionRects[i]
+		rects[w.collisionRects[i]] = r
+		w.collisionRects[i] = r
+	}
+	for i, k := range s.kinematics {
+		*k = *w.kinematics[i]
+		k.capsule = capsules[k.capsule]
+		k.rect = rects[k.rect]
+		w.kinematics[i] = k
+	}
+
+	for i, c := range s.chains {
+		if w.selectedChain.chain == w.chains[i] {
+			w.selectedChain.chain = c
+		}
+		*c = *w.chains[i]
+		w.chains[i] = c
+	}
+	for i, c := range s.curves {
+		*c = *w.curves[i]
+		w.curves[i] = c
+	}
+	for i, a := range s.arenas {
+		*a = *w.arenas[i]
+		w.arenas[i] = a
+	}
+	portals := make(map[*Portal]*Portal, len(s.portals))
+	for i, p := range s.portals {
+		*p = *w.portals[i]
+		portals[w.portals[i]] = p
+		w.portals[i] = p
+	}
+	for _, p := range s.portals {
+		p.other = portals[p.other]
+	}
+
+	*e = *w
+	e.accel = e.accelerations
+	e.onTouch = onTouch
+	e.onTouchCapsule = onTouchCapsule
+}
+
+// deepClone is Clone that also copies the static colliders and the
+// solvers' caches, so nothing is shared with e.
+func (e *Engine) deepClone() *Engine {
+	c := e.Clone()
+
+	chains := make(map[*Chain]*Chain, len(e.chains))
+	c.chains = make([]*Chain, len(e.chains))
+	for i, chain := range e.chains {
+		copied := *chain
+		copied.points = append([]Vec2(nil), chain.points...)
+		c.chains[i] = &copied
+		chains[chain] = &copied
+	}
+	c.selectedChain.chain = chains[e.selectedChain.chain]
+	c.curves = make([]*Curve, len(e.curves))
+	for i, curve := range e.curves {
+		copied := *curve
+		copied.points = append([]Vec2(nil), curve.points...)
+		copied.params = append([]float64(nil), curve.params...)
+		c.curves[i] = &copied
+	}
+	c.arenas = make([]*Arena, len(e.arenas))
+	for i, a := range e.arenas {
+		copied := *a
+		copied.points = append([]Vec2(nil), a.points...)
+		copied.normals = append([]Vec2(nil), a.normals...)
+		c.arenas[i] = &copied
+	}
+	portals := make(map[*Portal]*Portal, len(e.portals))
+	c.portals = make([]*Portal, len(e.portals))
+	for i, p := range e.portals {
+		copied := &Portal{start: p.start, end: p.end, color: p.color}
+		c.portals[i] = copied
+		portals[p] = copied
+	}
+	for i, p := range e.portals {
+		c.portals[i].other = portals[p.other]
+	}
+
+	c.fluid.pairs = append([]collidingPair(nil), e.fluid.pairs...)
+	c.fluid.density = append([]float64(nil), e.fluid.density...)
+	c.fluid.pressure = append([]float64(nil), e.fluid.pressure...)
+	c.nbody.nodes = append([]bhNode(nil), e.nbody.nodes...)
+	c.coulomb.pairs = append([]collidingPair(nil), e.coulomb.pairs...)
+	c.collidingPairs = append([]collidingPair(nil), e.collidingPairs...)
+	c.collidingCapsules = append([]collidingCapsule(nil), e.collidingCapsules...)
+	c.walls = append([]wallContact(nil), e.walls...)
+	return c
+}
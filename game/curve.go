@@ -0,0 +1,244 @@
+package game
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+type curveKind int
+
+const (
+	curveQuadratic curveKind = iota
+	curveCubic
+	curveArc
+)
+
+// Curves are flattened until the polyline is within this distance of the
+// curve. The polyline is only used for drawing and for a first guess of the
+// closest point, collisions are against the curve itself.
+const curveTolerance = 0.25
+
+// NewQuadraticCurve creates a curved wall along the quadratic Bézier curve
+// from p0 to p2 with control point p1.
+func NewQuadraticCurve(p0, p1, p2 Vec2, radius float64) *Curve {
+	c := &Curve{
+		kind:     curveQuadratic,
+		controls: [4]Vec2{p0, p1, p2},
+		radius:   radius,
+	}
+	c.flatten()
+	return c
+}
+
+// NewCubicCurve creates a curved wall along the cubic Bézier curve from p0
+// to p3 with control points p1 and p2.
+func NewCubicCurve(p0, p1, p2, p3 Vec2, radius float64) *Curve {
+	c := &Curve{
+		kind:     curveCubic,
+		controls: [4]Vec2{p0, p1, p2, p3},
+		radius:   radius,
+	}
+	c.flatten()
+	return c
+}
+
+// NewArc creates a curved wall along the circle around center, from the
+// start angle through sweep radians. Angles are measured like Vec2.Angle.
+func NewArc(center Vec2, arcRadius, start, sweep, radius float64) *Curve {
+	if sweep < 0 {
+		start += sweep
+		sweep = -sweep
+	}
+	c := &Curve{
+		kind:      curveArc,
+		center:    center,
+		arcRadius: arcRadius,
+		start:     start,
+		sweep:     math.Min(sweep, twoPi),
+		radius:    radius,
+	}
+	c.flatten()
+	return c
+}
+
+// Curve is a smooth static wall with thickness, a Bézier curve or a circular
+// arc. Circles roll along it without bumping over facets since the contact
+// normal comes from the closest point on the curve.
+type Curve struct {
+	kind     curveKind
+	controls [4]Vec2 // Bézier control points
+
+	center    Vec2 // of an arc
+	arcRadius float64
+	start     float64 // angle where an arc starts
+	sweep     float64 // angle an arc covers, positive
+
+	radius float64 // half the thickness of the wall
+
+	points []Vec2    // flattened polyline
+	params []float64 // curve parameter at each point
+	low    Vec2
+	high   Vec2
+}
+
+// at returns the point on the curve at parameter t in 0..1.
+func (c *Curve) at(t float64) Vec2 {
+	p := c.controls
+	u := 1 - t
+	switch c.kind {
+	case curveQuadratic:
+		return p[0].Scaled(u * u).Add(p[1].Scaled(2 * u * t)).Add(p[2].Scaled(t * t))
+	case curveCubic:
+		return p[0].Scaled(u * u * u).Add(p[1].Scaled(3 * u * u * t)).Add(p[2].Scaled(3 * u * t * t)).Add(p[3].Scaled(t * t * t))
+	}
+	angle := c.start + c.sweep*t
+	return c.center.Add(Vec2{math.Cos(angle), math.Sin(angle)}.Scaled(c.arcRadius))
+}
+
+// derivatives returns the first and second derivatives of a Bézier curve at
+// t.
+func (c *Curve) derivatives(t float64) (Vec2, Vec2) {
+	p := c.controls
+	u := 1 - t
+	if c.kind == curveQuadratic {
+		d1 := p[0].To(p[1]).Scaled(2 * u).Add(p[1].To(p[2]).Scaled(2 * t))
+		d2 := p[1].To(p[2]).Sub(p[0].To(p[1])).Scaled(2)
+		return d1, d2
+	}
+	a := p[0].To(p[1])
+	b := p[1].To(p[2])
+	d := p[2].To(p[3])
+	d1 := a.Scaled(3 * u * u).Add(b.Scaled(6 * u * t)).Add(d.Scaled(3 * t * t))
+	d2 := b.Sub(a).Scaled(6 * u).Add(d.Sub(b).Scaled(6 * t))
+	return d1, d2
+}
+
+// flatten the curve into a polyline, subdividing where it bends the most.
+func (c *Curve) flatten() {
+	c.points = append(c.points[:0], c.at(0))
+	c.params = append(c.params[:0], 0)
+	c.subdivide(0, 1, 0)
+
+	c.low = c.points[0]
+	c.high = c.points[0]
+	for _, p := range c.points {
+		c.low = Vec2{math.Min(c.low.X, p.X), math.Min(c.low.Y, p.Y)}
+		c.high = Vec2{math.Max(c.high.X, p.X), math.Max(c.high.Y, p.Y)}
+	}
+}
+
+// subdivide appends the points after t0 up to and including t1.
+func (c *Curve) subdivide(t0, t1 float64, depth int) {
+	mid := (t0 + t1) / 2
+	chordMid := c.at(t0).Add(c.at(t1)).Scaled(0.5)
+	// always split a few times so a symmetric curve can't look straight
+	if depth < 3 || (depth < 12 && chordMid.To(c.at(mid)).Len() > curveTolerance) {
+		c.subdivide(t0, mid, depth+1)
+		c.subdivide(mid, t1, depth+1)
+		return
+	}
+	c.points = append(c.points, c.at(t1))
+	c.params = append(c.params, t1)
+}
+
+// closest returns the point on the curve closest to p.
+func (c *Curve) closest(p Vec2) Vec2 {
+	if c.kind == curveArc {
+		d := c.center.To(p)
+		if d.Len() > 0 {
+			// angle past the start, in 0..2pi
+			rel := math.Mod(d.Angle()-c.start, twoPi)
+			if rel < 0 {
+				rel += twoPi
+			}
+			if rel <= c.sweep {
+				return c.center.Add(d.Unit().Scaled(c.arcRadius))
+			}
+		}
+		// outside the arc, one of the ends is closest
+		a := c.at(0)
+		b := c.at(1)
+		if a.To(p).Len() < b.To(p).Len() {
+			return a
+		}
+		return b
+	}
+
+	// best segment of the polyline as a first guess
+	best := math.MaxFloat64
+	t := 0.0
+	for k := 0; k < len(c.points)-1; k++ {
+		s := closestOnSegment(c.points[k], c.points[k+1], p)
+		q := c.points[k].Add(c.points[k].To(c.points[k+1]).Scaled(s))
+		if d := q.To(p).Len(); d < best {
+			best = d
+			t = c.params[k] + (c.params[k+1]-c.params[k])*s
+		}
+	}
+	// then Newton's method on the distance, which converges in a few steps
+	// from there
+	for iter := 0; iter < 4; iter++ {
+		d1, d2 := c.derivatives(t)
+		v := p.To(c.at(t))
+		f := v.Dot(d1)
+		df := d1.Dot(d1) + v.Dot(d2)
+		if df <= 0 {
+			break
+		}
+		t = clamp(t-f/df, 0, 1)
+	}
+	return c.at(t)
+}
+
+func (e *Engine) addCurve(c *Curve) {
+	e.curves = append(e.curves, c)
+}
+
+// removeCurve takes c out of the engine. It returns false if c wasn't in it.
+func (e *Engine) removeCurve(c *Curve) bool {
+	for i, curve := range e.curves {
+		if curve == c {
+			e.curves = append(e.curves[:i], e.curves[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// newDemoCurves returns a half pipe on the bottom right and a slide on the
+// top left of a world of the given size.
+func newDemoCurves(w, h float64) []*Curve {
+	return []*Curve{
+		NewArc(Vec2{w * 0.82, h * 0.7}, h*0.2, 0, math.Pi, 5),
+		NewCubicCurve(Vec2{w * 0.05, h * 0.1}, Vec2{w * 0.2, h * 0.45}, Vec2{w * 0.25, h * 0.05}, Vec2{w * 0.4, h * 0.3}, 5),
+	}
+}
+
+// resolveCurve pushes body i out of the curve.
+func (e *Engine) resolveCurve(c *Curve, i int) {
+	r := e.bodies.radius[i]
+	reach := c.radius + r
+	p := e.bodies.pos[i]
+	if p.X < c.low.X-reach || p.X > c.high.X+reach || p.Y < c.low.Y-reach || p.Y > c.high.Y+reach {
+		return
+	}
+	v := c.closest(p).To(p)
+	dist := v.Len()
+	if dist >= reach || dist == 0 {
+		return
+	}
+	e.pushFromWall(i, v.Scaled(1.0/dist), reach-dist, 1)
+}
+
+// Draw the curve as a thick line along its polyline.
+func (c *Curve) Draw(screen *ebiten.Image) {
+	clr := colorful.Hsl(0, 0, 0.5)
+	for k := 0; k < len(c.points)-1; k++ {
+		drawLine(c.points[k], c.points[k+1], c.radius*2, screen, clr, 1.0)
+	}
+	// round ends, like a capsule
+	drawCircleOutline(screen, c.points[0], c.radius/2, c.radius, clr)
+	drawCircleOutline(screen, c.points[len(c.points)-1], c.radius/2, c.radius, clr)
+}
@@ -0,0 +1,88 @@
+package game
+
+import (
+	"math"
+	"strconv"
+)
+
+// DragModel selects how a body loses speed to the medium it moves through.
+type DragModel int
+
+const (
+	// DragWorld makes a body use the engine's drag. It is the zero value, so
+	// bodies follow the world unless they are given their own setting.
+	DragWorld DragModel = iota
+	// DragNone disables drag.
+	DragNone
+	// DragLinear slows bodies proportionally to their velocity, like a body
+	// moving through a thick fluid.
+	DragLinear
+	// DragQuadratic slows bodies proportionally to the square of their speed,
+	// like air resistance.
+	DragQuadratic
+	// DragRolling slows bodies by the same amount whatever their speed, like
+	// a ball rolling on cloth.
+	DragRolling
+)
+
+func (m DragModel) String() string {
+	switch m {
+	case DragWorld:
+		return "world"
+	case DragNone:
+		return "none"
+	case DragLinear:
+		return "linear"
+	case DragQuadratic:
+		return "quadratic"
+	case DragRolling:
+		return "rolling"
+	}
+	return "unknown"
+}
+
+// Drag is a drag model together with its coefficient.
+type Drag struct {
+	Model       DragModel
+	Coefficient float64
+}
+
+var (
+	defaultLinearDrag    = Drag{DragLinear, 0.01}
+	defaultQuadraticDrag = Drag{DragQuadratic, 0.0005}
+)
+
+// accel returns the acceleration caused by drag on a body moving at v.
+func (d Drag) accel(v Vec2) Vec2 {
+	switch d.Model {
+	case DragLinear:
+		return v.Scaled(-d.Coefficient)
+	case DragQuadratic:
+		return v.Scaled(-d.Coefficient * v.Len())
+	case DragRolling:
+		// fade out below the coefficient instead of overshooting and
+		// turning the body around
+		return v.Scaled(-d.Coefficient / math.Max(v.Len(), d.Coefficient))
+	}
+	return Vec2{0, 0}
+}
+
+func (d Drag) String() string {
+	if d.Model == DragNone || d.Model == DragWorld {
+		return d.Model.String()
+	}
+	return d.Model.String() + " " + strconv.FormatFloat(d.Coefficient, 'f', 4, 64)
+}
+
+// cycleDrag switches the world drag to the next model, using its default
+// coefficient.
+func (e *Engine) cycleDrag() {
+	switch e.drag.Model {
+	case DragNone:
+		e.drag = defaultLinearDrag
+	case DragLinear:
+		e.drag = defaultQuadraticDrag
+	default:
+		e.drag = Drag{DragNone, 0}
+	}
+}
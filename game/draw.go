@@ -1,6 +1,7 @@
 package game
 
 import (
+	"image"
 	"image/color"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -9,9 +10,17 @@ import (
 
 var rect1x1 *ebiten.Image
 
+// Triangles are drawn by sampling the middle pixel of a white 3x3 image so
+// that filtering at the edges doesn't darken them.
+var triangleImage *ebiten.Image
+
 func init() {
 	rect1x1 = ebiten.NewImage(1, 1)
 	rect1x1.Fill(color.White)
+
+	img := ebiten.NewImage(3, 3)
+	img.Fill(color.White)
+	triangleImage = img.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
 }
 
 func drawLine(start, end Vec2, thickness float64, target *ebiten.Image, color colorful.Color, alpha float64) {
@@ -28,3 +37,76 @@ func drawLine(start, end Vec2, thickness float64, target *ebiten.Image, color co
 	op.GeoM.Translate(start.X-offset.X, start.Y-offset.Y)
 	target.DrawImage(rect1x1, op)
 }
+
+// drawPolygon fills a polygon as a fan of triangles around center, which must
+// be inside the polygon (any point works for convex and star shaped ones).
+// The vertices and indices are appended to the given slices, which are
+// returned so callers can reuse them between frames.
+func drawPolygon(points []Vec2, center Vec2, target *ebiten.Image, color colorful.Color, alpha float64, vertices []ebiten.Vertex, indices []uint16) ([]ebiten.Vertex, []uint16) {
+	vertices = vertices[:0]
+	indices = indices[:0]
+	if len(points) < 3 {
+		return vertices, indices
+	}
+	r := float32(color.R)
+	g := float32(color.G)
+	b := float32(color.B)
+	a := float32(alpha)
+	vertex := func(p Vec2) ebiten.Vertex {
+		return ebiten.Vertex{
+			DstX: float32(p.X), DstY: float32(p.Y),
+			SrcX: 1.5, SrcY: 1.5,
+			ColorR: r, ColorG: g, ColorB: b, ColorA: a,
+		}
+	}
+	vertices = append(vertices, vertex(center))
+	for i, p := range points {
+		vertices = append(vertices, vertex(p))
+		next := (i+1)%len(points) + 1
+		indices = append(indices, 0, uint16(i+1), uint16(next))
+	}
+	target.DrawTriangles(vertices, indices, triangleImage, nil)
+	return vertices, indices
+}
+
+// drawImageClipped draws img with its top left corner at topLeft, keeping
+// only the part on the side of the line through point that normal points to.
+// Like drawPolygon, it reuses and returns the vertex and index slices.
+func drawImageClipped(target, img *ebiten.Image, topLeft, point, normal Vec2, color colorful.Color, vertices []ebiten.Vertex, indices []uint16) ([]ebiten.Vertex, []uint16) {
+	vertices = vertices[:0]
+	indices = indices[:0]
+	w, h := img.Size()
+	corners := [4]Vec2{
+		topLeft,
+		topLeft.Add(Vec2{float64(w), 0}),
+		topLeft.Add(Vec2{float64(w), float64(h)}),
+		topLeft.Add(Vec2{0, float64(h)}),
+	}
+	vertex := func(p Vec2) ebiten.Vertex {
+		src := topLeft.To(p)
+		return ebiten.Vertex{
+			DstX: float32(p.X), DstY: float32(p.Y),
+			SrcX: float32(src.X), SrcY: float32(src.Y),
+			ColorR: float32(color.R), ColorG: float32(color.G), ColorB: float32(color.B), ColorA: 1,
+		}
+	}
+	// clip the image rectangle against the line, one edge at a time
+	for k, a := range corners {
+		b := corners[(k+1)%4]
+		da := point.To(a).Dot(normal)
+		db := point.To(b).Dot(normal)
+		if da >= 0 {
+			vertices = append(vertices, vertex(a))
+		}
+		if (da >= 0) != (db >= 0) {
+			vertices = append(vertices, vertex(a.Add(a.To(b).Scaled(da/(da-db)))))
+		}
+	}
+	for k := 2; k < len(vertices); k++ {
+		indices = append(indices, 0, uint16(k-1), uint16(k))
+	}
+	if len(indices) > 0 {
+		target.DrawTriangles(vertices, indices, img, nil)
+	}
+	return vertices, indices
+}
@@ -0,0 +1,154 @@
+package game
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// newCoulomb creates a disabled electrostatic force solver.
+func newCoulomb() *coulomb {
+	return &coulomb{
+		k:         5e5,
+		cutoff:    300,
+		softening: 5,
+	}
+}
+
+// coulomb applies electrostatic forces between charged circles. Like charges
+// repel and opposite charges attract. Only pairs closer than the cutoff
+// interact, and the force is shifted so that it fades to zero at the cutoff
+// instead of switching off abruptly.
+type coulomb struct {
+	enabled   bool
+	k         float64 // coulomb constant
+	cutoff    float64
+	softening float64
+
+	count int             // number of charged bodies in the engine
+	pairs []collidingPair // charged bodies within the cutoff, found once per substep
+}
+
+func (c *coulomb) active() bool {
+	return c.enabled && c.count > 0
+}
+
+func (c *coulomb) String() string {
+	if !c.enabled {
+		return "off"
+	}
+	return "on, " + strconv.Itoa(c.count) + " charged"
+}
+
+// findNeighbors collects the charged pairs for the coming substep using the
+// engine's sorted sweep.
+func (c *coulomb) findNeighbors(e *Engine) {
+	charge := e.bodies.charge
+	c.pairs = e.neighborPairs(c.cutoff, c.pairs[:0])
+	n := 0
+	for _, p := range c.pairs {
+		if charge[p.a] != 0 && charge[p.b] != 0 {
+			c.pairs[n] = p
+			n++
+		}
+	}
+	c.pairs = c.pairs[:n]
+}
+
+// force returns the magnitude of the force between charges qa and qb at
+// squared distance d2. Positive values push the charges apart.
+func (c *coulomb) force(qa, qb, d2 float64) float64 {
+	cutoff2 := c.cutoff * c.cutoff
+	if d2 >= cutoff2 {
+		return 0
+	}
+	return c.k * qa * qb * (1/(d2+c.softening*c.softening) - 1/cutoff2)
+}
+
+// apply adds the electrostatic acceleration of every charged body to acc.
+func (c *coulomb) apply(e *Engine, pos, acc []Vec2) {
+	charge := e.bodies.charge
+	invMass := e.bodies.invMass
+	for _, p := range c.pairs {
+		a := p.a
+		b := p.b
+		d := e.delta(pos[b], pos[a])
+		d2 := d.Dot(d)
+		if d2 == 0 {
+			continue
+		}
+		f := d.Scaled(c.force(charge[a], charge[b], d2) / math.Sqrt(d2))
+		acc[a] = acc[a].Add(f.Scaled(invMass[a]))
+		acc[b] = acc[b].Sub(f.Scaled(invMass[b]))
+	}
+}
+
+// setCharge changes the charge of a circle in the engine.
+func (e *Engine) setCharge(circle *Circle, q float64) {
+	if circle.charge != 0 {
+		e.coulomb.count--
+	}
+	if q != 0 {
+		e.coulomb.count++
+	}
+	circle.charge = q
+	e.bodies.charge[circle.index] = q
+}
+
+// fieldAt returns the electric field at p, the force a unit positive charge
+// would feel there.
+func (e *Engine) fieldAt(p Vec2) Vec2 {
+	field := Vec2{0, 0}
+	for i, q := range e.bodies.charge {
+		if q == 0 {
+			continue
+		}
+		d := e.delta(e.bodies.pos[i], p)
+		d2 := d.Dot(d)
+		if d2 == 0 {
+			continue
+		}
+		field = field.Add(d.Scaled(e.coulomb.force(q, 1, d2) / math.Sqrt(d2)))
+	}
+	return field
+}
+
+// drawFieldGrid draws a short line along the field at every grid point,
+// brighter and longer where the field is stronger.
+func drawFieldGrid(screen *ebiten.Image, e *Engine, spacing float64) {
+	if e.coulomb.count == 0 {
+		return
+	}
+	for y := spacing / 2; y < e.height; y += spacing {
+		for x := spacing / 2; x < e.width; x += spacing {
+			p := Vec2{x, y}
+			field := e.fieldAt(p)
+			strength := field.Len()
+			if strength < 1e-3 {
+				continue
+			}
+			// log scale, field strength varies over orders of magnitude
+			t := clamp(remap(math.Log10(strength), -3, 1, 0, 1), 0, 1)
+			end := p.Add(field.Unit().Scaled(spacing * 0.45 * t))
+			drawLine(p, end, 2, screen, colorful.Hcl(60, t, 0.4+0.5*t).Clamped(), 0.3+0.6*t)
+		}
+	}
+}
+
+// drawChargeMarkers labels charged circles with their sign.
+func drawChargeMarkers(screen *ebiten.Image, e *Engine) {
+	for i, q := range e.bodies.charge {
+		if q == 0 || e.circles[i].hidden {
+			continue
+		}
+		sign := "+"
+		if q < 0 {
+			sign = "-"
+		}
+		p := e.bodies.pos[i]
+		ebitenutil.DebugPrintAt(screen, sign, int(p.X)-3, int(p.Y)-8)
+	}
+}
@@ -0,0 +1,166 @@
+package game
+
+import (
+	"math"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Falloff controls how a radial impulse weakens away from its center.
+type Falloff int
+
+const (
+	// FalloffConstant gives every body in range the full impulse.
+	FalloffConstant Falloff = iota
+	// FalloffLinear fades the impulse to zero at the edge of the radius.
+	FalloffLinear
+	// FalloffQuadratic fades the impulse faster, concentrating it near the
+	// center.
+	FalloffQuadratic
+)
+
+// scale returns the fraction of the impulse at t, the distance from the
+// center as a fraction of the radius.
+func (f Falloff) scale(t float64) float64 {
+	switch f {
+	case FalloffLinear:
+		return 1 - t
+	case FalloffQuadratic:
+		return (1 - t) * (1 - t)
+	}
+	return 1
+}
+
+// bodiesInRadius appends the index of every body that overlaps the circle at
+// center to out. It is an area query only: bodies are found whether or not
+// something lies between them and the center.
+func (e *Engine) bodiesInRadius(center Vec2, radius float64, out []int) []int {
+	// circles added since the last update are not in place yet
+	e.sortCircles()
+
+	if e.wrap && 2*(radius+e.maxRadius) > e.width {
+		// the ranges either side of a seam would overlap and find bodies
+		// twice, and between them they cover everything anyway
+		for i := range e.bodies.pos {
+			if e.reaches(center, radius, i) {
+				out = append(out, i)
+			}
+		}
+		return out
+	}
+	out = e.searchRadius(center, radius, out)
+	if e.wrap {
+		// the circle may reach across the left or right seam
+		if center.X-radius-e.maxRadius < 0 {
+			out = e.searchRadius(center.Add(Vec2{e.width, 0}), radius, out)
+		}
+		if center.X+radius+e.maxRadius > e.width {
+			out = e.searchRadius(center.Sub(Vec2{e.width, 0}), radius, out)
+		}
+	}
+	return out
+}
+
+// searchRadius is bodiesInRadius without the seams, the order must be sorted.
+func (e *Engine) searchRadius(center Vec2, radius float64, out []int) []int {
+	pos := e.bodies.pos
+	order := e.order
+	// the order is sorted by x, so skip straight to the first body that could
+	// be in range
+	lo := center.X - radius - e.maxRadius
+	start := sort.Search(len(order), func(k int) bool {
+		return pos[order[k]].X >= lo
+	})
+	for _, i := range order[start:] {
+		if pos[i].X > center.X+radius+e.maxRadius {
+			break
+		}
+		if e.reaches(center, radius, i) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// reaches reports whether body i overlaps the circle at center.
+func (e *Engine) reaches(center Vec2, radius float64, i int) bool {
+	d := e.delta(center, e.bodies.pos[i])
+	reach := radius + e.bodies.radius[i]
+	return d.Dot(d) < reach*reach
+}
+
+// ApplyRadialImpulse pushes every body within radius of center directly away
+// from it. strength is the impulse at the center, so heavier circles are
+// pushed less, and falloff controls how it fades towards the edge. No circle
+// is sent faster than the substeps can follow at normal speed, so small ones
+// don't tunnel through whatever is next to them.
+func (e *Engine) ApplyRadialImpulse(center Vec2, radius, strength float64, falloff Falloff) {
+	maxKick := e.stepTravel * e.minRadius * float64(e.maxSteps)
+	e.queried = e.bodiesInRadius(center, radius, e.queried[:0])
+	for _, i := range e.queried {
+		d := e.delta(center, e.bodies.pos[i])
+		dist := d.Len()
+		var dir Vec2
+		if dist == 0 {
+			// right on the center, pick any direction
+			dir = Vec2{0, -1}
+		} else {
+			dir = d.Scaled(1.0 / dist)
+		}
+		t := math.Min(dist/radius, 1)
+		push := math.Min(strength*falloff.scale(t), maxKick/e.bodies.invMass[i])
+		impulse := dir.Scaled(push)
+		e.ApplyImpulseAt(e.circles[i], e.bodies.pos[i], impulse)
+	}
+}
+
+// ApplyImpulseAt applies an impulse to a circle at point. Circles don't
+// rotate, so the point doesn't change the result yet, and the whole impulse
+// goes into the velocity of the circle's center.
+func (e *Engine) ApplyImpulseAt(circle *Circle, point, impulse Vec2) {
+	i := circle.index
+	dv := impulse.Scaled(e.bodies.invMass[i])
+	e.bodies.impulse[i] = e.bodies.impulse[i].Add(dv)
+	circle.activity += dv.Len() * 0.1
+}
+
+// shockwave is the expanding ring drawn for an explosion.
+type shockwave struct {
+	center Vec2
+	radius float64 // final radius, the same as the explosion's
+	age    int
+}
+
+const shockwaveFrames = 30
+
+// updateShockwaves ages every shockwave and drops the ones that finished.
+func updateShockwaves(waves []shockwave) []shockwave {
+	n := 0
+	for _, w := range waves {
+		w.age++
+		if w.age < shockwaveFrames {
+			waves[n] = w
+			n++
+		}
+	}
+	return waves[:n]
+}
+
+// Draw the shockwave as a ring that grows quickly and fades out.
+func (w shockwave) Draw(screen *ebiten.Image) {
+	t := float64(w.age) / shockwaveFrames
+	// ease out so the ring starts fast like a blast front
+	r := w.radius * (1 - (1-t)*(1-t))
+	alpha := 1 - t
+	clr := colorful.Hcl(60, 0.6, 0.9).Clamped()
+	const segments = 48
+	prev := w.center.Add(Vec2{r, 0})
+	for s := 1; s <= segments; s++ {
+		angle := twoPi * float64(s) / segments
+		next := w.center.Add(Vec2{math.Cos(angle), math.Sin(angle)}.Scaled(r))
+		drawLine(prev, next, 6*(1-t)+1, screen, clr, alpha)
+		prev = next
+	}
+}
@@ -0,0 +1,36 @@
+package game
+
+import "testing"
+
+func TestBodiesInRadiusAcrossSeams(t *testing.T) {
+	e := newTestEngine(100)
+	e.wrap = true
+	for _, radius := range []float64{50, 250, 400} {
+		for _, center := range []Vec2{{10, 300}, {400, 300}, {790, 300}} {
+			found := make(map[int]int)
+			for _, i := range e.bodiesInRadius(center, radius, nil) {
+				found[i]++
+			}
+			for i := range e.bodies.pos {
+				want := 0
+				if e.reaches(center, radius, i) {
+					want = 1
+				}
+				if found[i] != want {
+					t.Errorf("radius %v at %v: body %v found %v times, want %v", radius, center, i, found[i], want)
+				}
+			}
+		}
+	}
+}
+
+func TestRadialImpulseSpeedLimit(t *testing.T) {
+	e := newTestEngine(0)
+	small := NewCircle(400, 300, 3, nil)
+	e.addCircle(small)
+	e.ApplyRadialImpulse(Vec2{390, 300}, 250, 30000, FalloffLinear)
+	limit := e.stepTravel * e.minRadius * float64(e.maxSteps)
+	if kick := e.bodies.impulse[small.index].Len(); kick > limit+1e-9 {
+		t.Errorf("kicked to %v, want at most %v", kick, limit)
+	}
+}
@@ -0,0 +1,232 @@
+package game
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// fluidParticleRadius is the radius of every fluid particle. The SPH settings
+// are derived from it, so particles of other sizes would not settle properly.
+const fluidParticleRadius = 4.0
+
+// NewFluidParticle creates a circle that is simulated as an SPH particle.
+// Particles push each other apart with pressure instead of colliding, but
+// collide with capsules, rectangles and regular circles as usual.
+func NewFluidParticle(x, y float64, shader *ebiten.Shader) *Circle {
+	c := NewCircle(x, y, fluidParticleRadius, shader)
+	c.fluid = true
+	c.hidden = true
+	return c
+}
+
+// newFluid creates SPH settings for particles of radius r. The smoothing
+// radius and rest density are derived from a hexagonal packing of particles
+// that just touch, so the fluid settles with particles side by side.
+func newFluid(r float64) *fluid {
+	f := &fluid{
+		h:         r * 4,
+		stiffness: 400,
+		viscosity: 0.3,
+		gravity:   Vec2{0, 0.25},
+	}
+	f.poly6 = 4.0 / (math.Pi * math.Pow(f.h, 8))
+	f.spikyGrad = -30.0 / (math.Pi * math.Pow(f.h, 5))
+	f.viscLaplacian = 40.0 / (math.Pi * math.Pow(f.h, 5))
+
+	spacing := r * 2
+	rowHeight := spacing * math.Sqrt(3) / 2
+	n := int(f.h/spacing) + 1
+	for row := -n; row <= n; row++ {
+		for col := -n; col <= n; col++ {
+			x := float64(col) * spacing
+			if row%2 != 0 {
+				x += spacing / 2
+			}
+			y := float64(row) * rowHeight
+			f.restDensity += f.kernel(x*x + y*y)
+		}
+	}
+	return f
+}
+
+// fluid implements smoothed particle hydrodynamics with the kernels from
+// Müller et al. 2003, "Particle-Based Fluid Simulation for Interactive
+// Applications". Every particle has unit mass.
+type fluid struct {
+	h           float64 // smoothing radius
+	restDensity float64
+	stiffness   float64 // pressure per unit of density above rest, the speed of sound squared
+	viscosity   float64
+	gravity     Vec2 // only applies to fluid particles, so water can pour in the top down sandbox
+
+	// kernel normalization constants for the smoothing radius
+	poly6         float64
+	spikyGrad     float64
+	viscLaplacian float64
+
+	count    int             // number of particles in the engine
+	pairs    []collidingPair // particles within h of each other, found once per substep
+	density  []float64
+	pressure []float64
+}
+
+// kernel returns the poly6 density weight of a neighbour at squared
+// distance d2.
+func (f *fluid) kernel(d2 float64) float64 {
+	h2 := f.h * f.h
+	if d2 >= h2 {
+		return 0
+	}
+	x := h2 - d2
+	return f.poly6 * x * x * x
+}
+
+// findNeighbors collects the particle pairs for the coming substep using the
+// engine's sorted sweep.
+func (f *fluid) findNeighbors(e *Engine) {
+	isFluid := e.bodies.fluid
+	f.pairs = e.neighborPairs(f.h, f.pairs[:0])
+	// keep only the pairs where both bodies are particles
+	n := 0
+	for _, p := range f.pairs {
+		if isFluid[p.a] && isFluid[p.b] {
+			f.pairs[n] = p
+			n++
+		}
+	}
+	f.pairs = f.pairs[:n]
+}
+
+// apply adds gravity, pressure and viscosity to the acceleration of every
+// particle.
+func (f *fluid) apply(e *Engine, pos, vel, acc []Vec2) {
+	isFluid := e.bodies.fluid
+	n := len(pos)
+	if cap(f.density) < n {
+		f.density = make([]float64, n)
+		f.pressure = make([]float64, n)
+	}
+	f.density = f.density[:n]
+	f.pressure = f.pressure[:n]
+
+	// density, including each particle's own contribution
+	self := f.kernel(0)
+	for i := range pos {
+		f.density[i] = self
+	}
+	for _, p := range f.pairs {
+		d := e.delta(pos[p.a], pos[p.b])
+		w := f.kernel(d.Dot(d))
+		f.density[p.a] += w
+		f.density[p.b] += w
+	}
+
+	// pressure from the equation of state, clamped so particles only push
+	// and don't clump together at the surface
+	for i := range pos {
+		f.pressure[i] = math.Max(0, f.stiffness*(f.density[i]-f.restDensity))
+	}
+
+	for i := range pos {
+		if isFluid[i] {
+			acc[i] = acc[i].Add(f.gravity)
+		}
+	}
+
+	for _, p := range f.pairs {
+		a := p.a
+		b := p.b
+		d := e.delta(pos[b], pos[a])
+		dist := d.Len()
+		if dist == 0 || dist >= f.h {
+			continue
+		}
+		dir := d.Scaled(1.0 / dist)
+		x := f.h - dist
+
+		// symmetric pressure force along the spiky kernel gradient
+		shared := (f.pressure[a] + f.pressure[b]) * 0.5 * f.spikyGrad * x * x
+		acc[a] = acc[a].Sub(dir.Scaled(shared / (f.density[a] * f.density[b])))
+		acc[b] = acc[b].Add(dir.Scaled(shared / (f.density[a] * f.density[b])))
+
+		// viscosity pulls the velocities of neighbours together
+		visc := vel[a].To(vel[b]).Scaled(f.viscosity * f.viscLaplacian * x / (f.density[a] * f.density[b]))
+		acc[a] = acc[a].Add(visc)
+		acc[b] = acc[b].Sub(visc)
+	}
+}
+
+// newBlobImage creates the falloff drawn for every particle in the metaball
+// pass. The field is stored in all channels so it stays valid premultiplied
+// alpha and adds up when drawn with CompositeModeLighter.
+func newBlobImage(radius int) *ebiten.Image {
+	size := radius * 2
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx := (float64(x) + 0.5 - float64(radius)) / float64(radius)
+			dy := (float64(y) + 0.5 - float64(radius)) / float64(radius)
+			d2 := dx*dx + dy*dy
+			if d2 >= 1 {
+				continue
+			}
+			v := uint8((1 - d2) * (1 - d2) * 255)
+			img.SetRGBA(x, y, color.RGBA{v, v, v, v})
+		}
+	}
+	return ebiten.NewImageFromImage(img)
+}
+
+// fluidRenderer draws particles as a continuous liquid: each one adds a soft
+// blob to a field image, and a shader turns the field into a surface where it
+// crosses a threshold.
+type fluidRenderer struct {
+	field  *ebiten.Image
+	blob   *ebiten.Image
+	shader *ebiten.Shader
+	ghosts []Vec2
+}
+
+func newFluidRenderer(width, height int, shader *ebiten.Shader) *fluidRenderer {
+	return &fluidRenderer{
+		field:  ebiten.NewImage(width, height),
+		blob:   newBlobImage(int(fluidParticleRadius * 2.75)),
+		shader: shader,
+	}
+}
+
+func (r *fluidRenderer) Draw(screen *ebiten.Image, e *Engine) {
+	if e.fluid.count == 0 {
+		return
+	}
+	r.field.Clear()
+	w, h := r.blob.Size()
+	op := &ebiten.DrawImageOptions{}
+	op.CompositeMode = ebiten.CompositeModeLighter
+	for i, p := range e.bodies.pos {
+		if !e.bodies.fluid[i] {
+			continue
+		}
+		op.GeoM.Reset()
+		op.GeoM.Translate(p.X-float64(w)/2, p.Y-float64(h)/2)
+		r.field.DrawImage(r.blob, op)
+		// blobs near a seam also show on the other side
+		r.ghosts = e.ghostOffsets(p, float64(w)/2, r.ghosts[:0])
+		for _, offset := range r.ghosts {
+			op.GeoM.Translate(offset.X, offset.Y)
+			r.field.DrawImage(r.blob, op)
+			op.GeoM.Translate(-offset.X, -offset.Y)
+		}
+	}
+
+	sw, sh := r.field.Size()
+	sop := &ebiten.DrawRectShaderOptions{}
+	sop.Uniforms = map[string]interface{}{
+		"Color": []float32{0.1, 0.35, 0.8},
+	}
+	sop.Images[0] = r.field
+	screen.DrawRectShader(sw, sh, r.shader, sop)
+}
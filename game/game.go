@@ -31,8 +31,23 @@ type Game struct {
 	width             int
 	height            int
 	time              int
+	mode              mode
+	pinball           *pinball
+	billiards         *billiards
 	showFPS           bool
 	showDebug         bool
+	showStats         bool
+	showField         bool
+	fluidMode         bool
+	fluidRenderer     *fluidRenderer
+	shockwaves        []shockwave
+	ghosts            []Vec2 // reused when drawing circles across the seams
+	prediction        prediction
+	quickSave         *Snapshot
+	ramp              *Chain   // demo chain, nil while it is hidden
+	curves            []*Curve // demo curves, nil while they are hidden
+	history           history
+	statsPanel        *statsPanel
 	speedControl      *SpeedControl
 	engine            *Engine
 	circleShader      *ebiten.Shader
@@ -50,6 +65,10 @@ func NewGame(width, height int) *Game {
 	if err != nil {
 		log.Fatal("Circle shader failed: ", err)
 	}
+	metaballShader, err := ebiten.NewShader(shader.Metaball)
+	if err != nil {
+		log.Fatal("Metaball shader failed: ", err)
+	}
 
 	var circles []*Circle
 	// circles = append(circles, NewCircle(float64(width)/2, float64(height)/2, 200.0, color.White, sh))
@@ -67,33 +86,51 @@ func NewGame(width, height int) *Game {
 	// left
 	rectangles = append(rectangles, &collisionRect{
 		upperLeft:  Vec2{-w, -w},
-		lowerRight: Vec2{0, h + w}},
+		lowerRight: Vec2{0, h + w},
+		boundary:   true},
 	)
 	// right
 	rectangles = append(rectangles, &collisionRect{
 		upperLeft:  Vec2{w, -w},
-		lowerRight: Vec2{w * 2, h + w}},
+		lowerRight: Vec2{w * 2, h + w},
+		boundary:   true},
 	)
 	// top
 	rectangles = append(rectangles, &collisionRect{
 		upperLeft:  Vec2{0, -h * 2},
-		lowerRight: Vec2{w, 0}},
+		lowerRight: Vec2{w, 0},
+		boundary:   true},
 	)
 	// bottom
 	rectangles = append(rectangles, &collisionRect{
 		upperLeft:  Vec2{0, h},
-		lowerRight: Vec2{w, h * 2}},
+		lowerRight: Vec2{w, h * 2},
+		boundary:   true},
 	)
 
 	return &Game{
-		width:        width,
-		height:       height,
-		showFPS:      true,
-		showDebug:    true,
-		speedControl: NewSpeedControl(),
-		engine:       NewEngine(width, height, circles, capsules, rectangles),
-		circleShader: sh,
+		width:         width,
+		height:        height,
+		showFPS:       true,
+		showDebug:     true,
+		showStats:     true,
+		statsPanel:    newStatsPanel(),
+		fluidRenderer: newFluidRenderer(width, height, metaballShader),
+		speedControl:  NewSpeedControl(),
+		engine:        NewEngine(width, height, circles, capsules, rectangles),
+		circleShader:  sh,
+	}
+}
+
+// LoadLevel adds the collision geometry traced from the PNG mask at path,
+// stretched over the whole screen.
+func (g *Game) LoadLevel(path string) error {
+	level, err := LoadLevelFile(path, float64(g.width), float64(g.height))
+	if err != nil {
+		return err
 	}
+	g.engine.addLevel(level)
+	return nil
 }
 
 func cursorPosition() Vec2 {
@@ -110,22 +147,46 @@ func (g *Game) Update() error {
 
 	start := time.Now()
 
+	g.updateMode()
+	if s := g.scene(); s != nil {
+		g.speedControl.update()
+		s.update(g.speedControl.multiplier())
+		g.updateElapsedTime = time.Now().Sub(start)
+		return nil
+	}
+
 	cursorPos := cursorPosition()
 
-	// Left mouse button -> Drag capsule / Dynamic input
+	// Left mouse button -> Drag capsule or chain point / Dynamic input
+	// Shift + left mouse button -> Add a chain point
 	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		found := g.engine.selectCapsuleAtPostion(cursorPos)
+		found := false
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			found = g.engine.insertChainPointAt(cursorPos)
+		}
+		if !found {
+			found = g.engine.selectCapsuleAtPostion(cursorPos)
+		}
+		if !found {
+			found = g.engine.selectChainPointAt(cursorPos)
+		}
 		if !found {
 			g.engine.dynamicNearestPosition(cursorPos)
 		}
 	}
 	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
 		g.engine.moveSelectedCapsuleTo(cursorPos)
+		g.engine.moveSelectedChainPointTo(cursorPos)
 	}
 	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
 		g.engine.deselectCapsule()
+		g.engine.deselectChainPoint()
 		g.engine.dynamicRelease(cursorPos)
 	}
+	// Delete -> Remove the chain point under the cursor
+	if inpututil.IsKeyJustPressed(ebiten.KeyDelete) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+		g.engine.removeChainPointAt(cursorPos)
+	}
 
 	// // Right mouse button -> Pull the nearest circle
 	// if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
@@ -137,12 +198,64 @@ func (g *Game) Update() error {
 	// if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight) {
 	// 	g.engine.deselect()
 	// }
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) && !g.fluidMode {
 		radius := randRadius(5, 35)
 		circle := NewCircle(cursorPos.X, cursorPos.Y, radius, g.circleShader)
 		g.engine.addCircle(circle)
 	}
 
+	// Toggle fluid mode, where the right mouse button pours water
+	if inpututil.IsKeyJustPressed(ebiten.KeyW) {
+		g.fluidMode = !g.fluidMode
+	}
+
+	// Toggle n-body gravity, merging on contact, and adjust its opening angle
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		g.engine.nbody.enabled = !g.engine.nbody.enabled
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.engine.nbody.merge = !g.engine.nbody.merge
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyLeftBracket) {
+		g.engine.nbody.theta = math.Max(g.engine.nbody.theta-0.1, 0)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyRightBracket) {
+		g.engine.nbody.theta = math.Min(g.engine.nbody.theta+0.1, 1.5)
+	}
+
+	// Electrostatics: toggle the force and field grid, and charge the circle
+	// nearest to the cursor
+	if inpututil.IsKeyJustPressed(ebiten.KeyE) {
+		g.engine.coulomb.enabled = !g.engine.coulomb.enabled
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		g.showField = !g.showField
+	}
+	if circle := g.engine.circleNearestPosition(cursorPos); circle != nil {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEqual) {
+			g.engine.setCharge(circle, circle.charge+1)
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyMinus) {
+			g.engine.setCharge(circle, circle.charge-1)
+		}
+		if inpututil.IsKeyJustPressed(ebiten.Key0) {
+			g.engine.setCharge(circle, 0)
+		}
+	}
+
+	// Spawn a soft body blob at the cursor
+	if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		g.engine.addSoftBody(NewSoftBody(cursorPos, 60, 6, g.circleShader))
+	}
+
+	// Middle mouse button -> Explosion
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) {
+		radius := 250.0
+		g.engine.ApplyRadialImpulse(cursorPos, radius, 30000, FalloffLinear)
+		g.shockwaves = append(g.shockwaves, shockwave{center: cursorPos, radius: radius})
+	}
+	g.shockwaves = updateShockwaves(g.shockwaves)
+
 	// Toggle display of FPS and debug text/lines
 	if inpututil.IsKeyJustPressed(ebiten.KeyD) {
 		g.showDebug = !g.showDebug
@@ -150,6 +263,85 @@ func (g *Game) Update() error {
 	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
 		g.showFPS = !g.showFPS
 	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		g.showStats = !g.showStats
+	}
+
+	// Quick save and quick load the whole world
+	if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+		g.quickSave = g.engine.Snapshot()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF9) && g.quickSave != nil {
+		g.engine.Restore(g.quickSave)
+		// the frames refer to the circles that were replaced
+		g.history.clear()
+	}
+
+	// Cycle through integrators
+	if inpututil.IsKeyJustPressed(ebiten.KeyI) {
+		g.engine.cycleIntegrator()
+	}
+
+	// Cycle through arenas
+	if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		g.engine.cycleArena()
+	}
+
+	// Spin the surface of the capsule or rectangle under the cursor
+	if inpututil.IsKeyJustPressed(ebiten.KeyU) {
+		g.engine.spinSurfaceAt(cursorPos)
+	}
+
+	// Make the capsule or rectangle under the cursor one-way
+	if inpututil.IsKeyJustPressed(ebiten.KeyY) {
+		g.engine.toggleOneWayAt(cursorPos)
+	}
+
+	// Toggle the demo moving platform, piston and sweeper
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		g.engine.toggleKinematics(g.circleShader)
+	}
+
+	// Toggle the demo portals
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.engine.togglePortals()
+	}
+
+	// Toggle the demo ramp, drag its points to reshape it
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		if g.ramp != nil && g.engine.removeChain(g.ramp) {
+			g.ramp = nil
+		} else {
+			g.ramp = newRamp(float64(g.width), float64(g.height))
+			g.engine.addChain(g.ramp)
+		}
+	}
+
+	// Toggle the demo half pipe and slide
+	if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		removed := false
+		for _, c := range g.curves {
+			removed = g.engine.removeCurve(c) || removed
+		}
+		if removed {
+			g.curves = nil
+		} else {
+			g.curves = newDemoCurves(float64(g.width), float64(g.height))
+			for _, c := range g.curves {
+				g.engine.addCurve(c)
+			}
+		}
+	}
+
+	// Toggle wrap-around world edges
+	if inpututil.IsKeyJustPressed(ebiten.KeyO) {
+		g.engine.wrap = !g.engine.wrap
+	}
+
+	// Cycle through world drag models
+	if inpututil.IsKeyJustPressed(ebiten.KeyA) {
+		g.engine.cycleDrag()
+	}
 
 	// Handle speed control keyboard inputs
 	g.speedControl.update()
@@ -176,11 +368,58 @@ func (g *Game) Update() error {
 			circle := NewCircle(xpos, ypos, radius, g.circleShader)
 			g.engine.addCircle(circle)
 		}
+		// water
+		maxFluid := 1500
+		if g.fluidMode && ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) {
+			for i := 0; g.engine.fluid.count < maxFluid && i < 3; i++ {
+				xpos := cursorPos.X + randFloat(-6, 6)
+				ypos := cursorPos.Y + randFloat(-6, 6)
+				g.engine.addCircle(NewFluidParticle(xpos, ypos, g.circleShader))
+			}
+		}
 	}
 
 	// TODO: get proper elapsed time
 	elapsedTime := 1.0
-	g.engine.update(g.width, g.height, g.speedControl.multiplier(), elapsedTime)
+	// R -> Rewind one frame per frame while held, carrying on from there
+	// branches off a new timeline
+	// L -> Advance one frame while paused
+	// Shift + L -> Advance one substep while paused
+	switch {
+	case ebiten.IsKeyPressed(ebiten.KeyR):
+		g.history.back(g.engine)
+	case g.speedControl.paused() && inpututil.IsKeyJustPressed(ebiten.KeyL):
+		advance := g.speedControl.advanceMultiplier()
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			g.engine.stepSubstep(advance, elapsedTime)
+		} else {
+			g.engine.update(g.width, g.height, advance, elapsedTime)
+		}
+		if !g.engine.midFrame() {
+			g.statsPanel.record(g.engine.Stats())
+			g.history.record(g.engine)
+		}
+	case g.speedControl.paused():
+		// keep resolving overlaps from dragging, unless a frame is being
+		// stepped through
+		if !g.engine.midFrame() {
+			g.engine.update(g.width, g.height, 0, elapsedTime)
+		}
+	default:
+		g.engine.update(g.width, g.height, g.speedControl.multiplier(), elapsedTime)
+		g.statsPanel.record(g.engine.Stats())
+		g.history.record(g.engine)
+	}
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		speed := g.speedControl.multiplier()
+		if g.speedControl.paused() {
+			// the shot goes when frames are stepped
+			speed = g.speedControl.advanceMultiplier()
+		}
+		g.prediction.update(g.engine, cursorPos, speed, g.width, g.height)
+	} else {
+		g.prediction.clear()
+	}
 
 	g.updateElapsedTime = time.Now().Sub(start)
 
@@ -196,6 +435,16 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 	screen.Fill(color.Black)
 
+	if s := g.scene(); s != nil {
+		s.draw(screen)
+		g.drawElapsedTime = time.Now().Sub(start)
+		return
+	}
+
+	if g.showField {
+		drawFieldGrid(screen, g.engine, 40)
+	}
+
 	// draw rectangles
 	if g.showDebug {
 		for _, rect := range g.engine.collisionRects {
@@ -205,18 +454,51 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		}
 	}
 
+	for _, a := range g.engine.arenas {
+		a.Draw(screen)
+	}
+	for _, c := range g.engine.chains {
+		c.Draw(screen, g.showDebug)
+	}
+	for _, c := range g.engine.curves {
+		c.Draw(screen)
+	}
+	for i := range g.engine.softBodies {
+		g.engine.softBodies[i].Draw(screen, g.engine)
+	}
+	g.fluidRenderer.Draw(screen, g.engine)
 	for i := range g.engine.circles {
+		if g.engine.circles[i].hidden {
+			continue
+		}
+		if p := g.engine.crossingPortal(g.engine.circles[i]); p != nil {
+			p.drawCrossing(screen, g.engine.circles[i])
+			continue
+		}
 		g.engine.circles[i].Draw(screen)
+		g.drawGhosts(screen, g.engine.circles[i])
+	}
+	for _, p := range g.engine.portals {
+		p.Draw(screen)
+	}
+	if g.showDebug {
+		drawSurfaceArrows(screen, g.engine, g.time)
+		drawOneWayMarks(screen, g.engine)
 	}
 	for i := range g.engine.capsules {
 		g.engine.capsules[i].Draw(screen)
 	}
+	drawChargeMarkers(screen, g.engine)
+	for _, w := range g.shockwaves {
+		w.Draw(screen)
+	}
 
-	// Draw dynamic input line
+	// Draw dynamic input line and where the shot will go
 	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
 		circle := g.engine.getDynamic()
 		if circle != nil {
 			drawLine(cursorPos, circle.pos, 2, screen, contrastColor(circle.color), 1.0)
+			g.prediction.Draw(screen, g.width, g.height)
 		}
 	}
 
@@ -246,10 +528,69 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			msg.WriteString("\n")
 		}
 		if g.showDebug {
-			msg.WriteString("Game speed: ")
+			msg.WriteString("Step: ")
+			msg.WriteString(strconv.Itoa(g.engine.frames))
+			if g.engine.midFrame() {
+				msg.WriteString(", substep ")
+				msg.WriteString(strconv.Itoa(g.engine.substep))
+				msg.WriteString("/")
+				msg.WriteString(strconv.Itoa(g.engine.steps))
+			}
+			if g.speedControl.paused() {
+				msg.WriteString(" (L frame, Shift+L substep)")
+			}
+			msg.WriteString("\nMode: ")
+			msg.WriteString(g.mode.String())
+			msg.WriteString(" (F2 pinball, F3 billiards)")
+			msg.WriteString("\nGame speed: ")
 			msg.WriteString(strconv.Itoa(g.speedControl.control))
 			msg.WriteString("\nCircle count: ")
 			msg.WriteString(strconv.Itoa(len(g.engine.circles)))
+			if g.fluidMode || g.engine.fluid.count > 0 {
+				msg.WriteString("\nFluid particles: ")
+				msg.WriteString(strconv.Itoa(g.engine.fluid.count))
+				if g.fluidMode {
+					msg.WriteString(" (pouring)")
+				}
+			}
+			msg.WriteString("\nIntegrator: ")
+			msg.WriteString(g.engine.integrator.String())
+			msg.WriteString("\nDrag: ")
+			msg.WriteString(g.engine.drag.String())
+			msg.WriteString("\nGravity: ")
+			msg.WriteString(g.engine.nbody.String())
+			msg.WriteString("\nCoulomb: ")
+			msg.WriteString(g.engine.coulomb.String())
+			msg.WriteString("\nArena: ")
+			if len(g.engine.arenas) == 0 {
+				msg.WriteString("none")
+			}
+			for k, a := range g.engine.arenas {
+				if k > 0 {
+					msg.WriteString(", ")
+				}
+				msg.WriteString(a.String())
+			}
+			msg.WriteString("\nWrap: ")
+			if g.engine.wrap {
+				msg.WriteString("on")
+			} else {
+				msg.WriteString("off")
+			}
+			msg.WriteString("\nQuick save: ")
+			if g.quickSave == nil {
+				msg.WriteString("none (F5 to save)")
+			} else {
+				msg.WriteString("F9 to load")
+			}
+			msg.WriteString("\nHistory: ")
+			msg.WriteString(g.history.String())
+			msg.WriteString(" (hold R to rewind)")
+			msg.WriteString("\nSubsteps: ")
+			msg.WriteString(strconv.Itoa(g.engine.steps))
+			msg.WriteString(" (")
+			msg.WriteString(g.engine.stepReason.String())
+			msg.WriteString(")")
 			msg.WriteString("\nChecks: ")
 			msg.WriteString(strconv.Itoa(g.engine.checks))
 			msg.WriteString("\nMax Speed: ")
@@ -277,10 +618,25 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		}
 		ebitenutil.DebugPrint(screen, msg.String())
 	}
+	if g.showDebug && g.showStats {
+		g.statsPanel.draw(screen, float64(g.width)-270, 10)
+	}
 
 	g.drawElapsedTime = time.Now().Sub(start)
 }
 
+// drawGhosts draws the copies of a circle that show on the other side of the
+// seams when the world wraps around.
+func (g *Game) drawGhosts(screen *ebiten.Image, c *Circle) {
+	g.ghosts = g.engine.ghostOffsets(c.pos, c.radius, g.ghosts[:0])
+	for _, offset := range g.ghosts {
+		ghost := *c
+		ghost.pos = ghost.pos.Add(offset)
+		ghost.prevPos = ghost.prevPos.Add(offset)
+		ghost.Draw(screen)
+	}
+}
+
 // Draw2 for testing shader
 func (g *Game) Draw2(screen *ebiten.Image) {
 	screen.Fill(color.RGBA{128, 128, 128, 255})
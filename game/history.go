@@ -0,0 +1,180 @@
+package game
+
+import "strconv"
+
+// historyFrames is how many frames can be rewound, ten seconds at 60 fps.
+const historyFrames = 600
+
+// historyFrame is the state of the bodies after one update. It is enough to
+// put the world back as long as the colliders weren't edited since, which
+// rewinding leaves as they are.
+//
+// Ten seconds of a busy sandbox is a lot of frames, so they are kept small:
+// positions and velocities are stored as float32, far finer than a pixel,
+// and prevPos as its offset from pos. The circles, their radii and charges
+// only change when circles are added, removed, merged or charged, so
+// consecutive frames share them. That comes to 24 bytes per circle per
+// frame, about 14 MB for a thousand circles, where full copies of the bodies
+// would take 38 MB.
+type historyFrame struct {
+	members *historyMembers
+	pos     []historyVec
+	prevPos []historyVec // offset from pos
+	vel     []historyVec
+	times   []float64 // of the kinematic colliders
+	frame   int       // the engine's frame counter
+}
+
+// historyMembers is the circles in the world with their sizes and charges,
+// in body order. Merging changes both.
+type historyMembers struct {
+	circles []*Circle
+	radius  []float64
+	charge  []float64
+}
+
+// same reports whether e has exactly these circles, at these sizes and
+// charges.
+func (m *historyMembers) same(e *Engine) bool {
+	if len(m.circles) != len(e.circles) {
+		return false
+	}
+	for i, c := range e.circles {
+		if m.circles[i] != c || m.radius[i] != e.bodies.radius[i] || m.charge[i] != e.bodies.charge[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// historyVec is a Vec2 stored at single precision.
+type historyVec struct {
+	X, Y float32
+}
+
+func toHistoryVec(v Vec2) historyVec {
+	return historyVec{float32(v.X), float32(v.Y)}
+}
+
+func (v historyVec) vec2() Vec2 {
+	return Vec2{float64(v.X), float64(v.Y)}
+}
+
+// history is a ring buffer of the last frames of the world. Rewinding drops
+// the newest frames, so carrying on from a rewound point starts a new
+// timeline from there.
+type history struct {
+	frames [historyFrames]historyFrame
+	start  int // oldest frame
+	count  int
+}
+
+func (h *history) String() string {
+	return strconv.Itoa(h.count) + " frames"
+}
+
+// clear forgets every frame, keeping their storage.
+func (h *history) clear() {
+	h.start = 0
+	h.count = 0
+}
+
+// record saves the state of e as the newest frame, overwriting the oldest
+// when the buffer is full.
+func (h *history) record(e *Engine) {
+	var members *historyMembers
+	if h.count > 0 {
+		members = h.frames[(h.start+h.count-1)%historyFrames].members
+	}
+	if members == nil || !members.same(e) {
+		members = &historyMembers{
+			circles: append([]*Circle(nil), e.circles...),
+			radius:  append([]float64(nil), e.bodies.radius...),
+			charge:  append([]float64(nil), e.bodies.charge...),
+		}
+	}
+
+	if h.count == historyFrames {
+		h.start = (h.start + 1) % historyFrames
+		h.count--
+	}
+	f := &h.frames[(h.start+h.count)%historyFrames]
+	h.count++
+	f.members = members
+	f.pos = f.pos[:0]
+	f.prevPos = f.prevPos[:0]
+	f.vel = f.vel[:0]
+	for i, p := range e.bodies.pos {
+		f.pos = append(f.pos, toHistoryVec(p))
+		f.prevPos = append(f.prevPos, toHistoryVec(e.bodies.prevPos[i].Sub(p)))
+		f.vel = append(f.vel, toHistoryVec(e.bodies.vel[i]))
+	}
+	f.frame = e.frames
+	f.times = f.times[:0]
+	for _, k := range e.kinematics {
+		f.times = append(f.times, k.time)
+	}
+}
+
+// back drops the newest frame and puts e back to the one before it. It
+// returns false when there is nothing left to rewind.
+func (h *history) back(e *Engine) bool {
+	if h.count <= 1 {
+		return false
+	}
+	h.count--
+	h.frames[(h.start+h.count-1)%historyFrames].restore(e)
+	return true
+}
+
+// restore puts the bodies of e back to the frame. Circles that were added
+// since are taken out and ones that were removed, by merging for example, are
+// put back.
+func (f *historyFrame) restore(e *Engine) {
+	if !f.members.same(e) {
+		selected := e.selectedCircle.pointer
+		for _, circle := range e.circles {
+			circle.index = -1
+		}
+		e.bodies = bodies{}
+		e.circles = e.circles[:0]
+		e.order = e.order[:0]
+		e.fluid.count = 0
+		e.coulomb.count = 0
+		e.selectedCircle.pointer = nil
+		for i, circle := range f.members.circles {
+			if circle.radius != f.members.radius[i] {
+				circle.setRadius(f.members.radius[i])
+			}
+			circle.pos = f.pos[i].vec2()
+			e.addCircle(circle)
+			e.setCharge(circle, f.members.charge[i])
+			if circle == selected {
+				e.selectedCircle.pointer = circle
+			}
+		}
+		e.measureSizes()
+	}
+	for i, circle := range e.circles {
+		pos := f.pos[i].vec2()
+		prevPos := pos.Add(f.prevPos[i].vec2())
+		vel := f.vel[i].vec2()
+		e.bodies.pos[i] = pos
+		e.bodies.prevPos[i] = prevPos
+		e.bodies.vel[i] = vel
+		e.bodies.impulse[i] = Vec2{0, 0}
+		circle.pos = pos
+		circle.prevPos = prevPos
+		circle.vel = vel
+	}
+	e.frames = f.frame
+	e.substep = 0
+	for i, k := range e.kinematics {
+		if i >= len(f.times) {
+			break
+		}
+		k.time = f.times[i]
+		offset, angle := k.path.pose(k.time)
+		k.place(offset, angle, motion{pivot: k.pivot.Add(offset)})
+	}
+}
@@ -0,0 +1,116 @@
+package game
+
+import "testing"
+
+// near reports whether a and b are within the precision history keeps.
+func near(a, b Vec2) bool {
+	return a.To(b).Len() < 1e-3
+}
+
+func TestHistoryWrapsAround(t *testing.T) {
+	e := newTestEngine(20)
+	h := &history{}
+	extra := 10
+	for i := 0; i < historyFrames+extra; i++ {
+		e.update(800, 600, 1, 1)
+		h.record(e)
+	}
+	if h.count != historyFrames {
+		t.Fatalf("kept %v frames, want %v", h.count, historyFrames)
+	}
+	if h.start != extra {
+		t.Errorf("oldest frame at %v, want %v", h.start, extra)
+	}
+
+	rewound := 0
+	for h.back(e) {
+		rewound++
+	}
+	if rewound != historyFrames-1 {
+		t.Errorf("rewound %v frames, want %v", rewound, historyFrames-1)
+	}
+	// the oldest frames were overwritten, so rewinding stops at the first
+	// frame still kept
+	if want := extra + 1; e.frames != want {
+		t.Errorf("rewound to frame %v, want %v", e.frames, want)
+	}
+}
+
+func TestHistoryBranchesOnResume(t *testing.T) {
+	e := newTestEngine(20)
+	c := e.circles[0]
+	h := &history{}
+	for i := 0; i < 20; i++ {
+		e.update(800, 600, 1, 1)
+		h.record(e)
+	}
+	for i := 0; i < 5; i++ {
+		h.back(e)
+	}
+	if e.frames != 15 {
+		t.Fatalf("rewound to frame %v, want 15", e.frames)
+	}
+
+	// carry on from the rewound frame with a push the first timeline didn't
+	// have, the new frames replace the ones rewound past
+	e.ApplyImpulseAt(c, c.pos, Vec2{0, -50 * c.area})
+	var branch []Vec2
+	for i := 0; i < 3; i++ {
+		e.update(800, 600, 1, 1)
+		h.record(e)
+		branch = append(branch, c.pos)
+	}
+	if h.count != 18 {
+		t.Fatalf("kept %v frames after resuming, want 18", h.count)
+	}
+
+	h.back(e)
+	if e.frames != 17 {
+		t.Errorf("rewound to frame %v, want 17", e.frames)
+	}
+	if !near(c.pos, branch[1]) {
+		t.Errorf("rewound to %v, want %v from the new timeline", c.pos, branch[1])
+	}
+	if !near(e.bodies.pos[c.index], c.pos) {
+		t.Errorf("body at %v, circle at %v", e.bodies.pos[c.index], c.pos)
+	}
+}
+
+func TestHistoryPutsBackMergedCircles(t *testing.T) {
+	e := newTestEngine(0)
+	a := NewCircle(400, 300, 10, nil)
+	b := NewCircle(425, 300, 10, nil)
+	e.addCircle(a)
+	e.addCircle(b)
+	e.setCharge(a, 2)
+	e.setCharge(b, 3)
+	e.nbody.enabled = true
+	e.nbody.merge = true
+	h := &history{}
+	h.record(e)
+	for i := 0; i < 60 && len(e.circles) == 2; i++ {
+		e.update(800, 600, 1, 1)
+		h.record(e)
+	}
+	if len(e.circles) != 1 {
+		t.Fatalf("circles didn't merge")
+	}
+	for h.back(e) {
+	}
+	if len(e.circles) != 2 || a.index < 0 || b.index < 0 {
+		t.Fatalf("got %v circles back, want both", len(e.circles))
+	}
+	if a.radius != 10 || e.bodies.radius[a.index] != 10 {
+		t.Errorf("radius %v, want 10", a.radius)
+	}
+	if !near(a.pos, Vec2{400, 300}) || !near(b.pos, Vec2{425, 300}) {
+		t.Errorf("circles at %v and %v", a.pos, b.pos)
+	}
+	if e.maxRadius != 10 {
+		t.Errorf("max radius %v, want 10", e.maxRadius)
+	}
+	// the merged circle carried both charges, rewinding mustn't add more
+	if a.charge != 2 || b.charge != 3 || e.bodies.charge[a.index] != 2 || e.coulomb.count != 2 {
+		t.Errorf("charges %v and %v over %v circles, want 2 and 3 over 2", a.charge, b.charge, e.coulomb.count)
+	}
+}
@@ -0,0 +1,208 @@
+package game
+
+// accelFunc writes the acceleration of every body into acc for the given
+// positions and velocities.
+type accelFunc func(pos, vel, acc []Vec2)
+
+// Integrator advances the position and velocity of every body by one substep
+// of length dt. Each Engine has its own integrator, so worlds can use
+// different ones side by side.
+type Integrator interface {
+	integrate(b *bodies, accel accelFunc, dt float64)
+	String() string
+}
+
+// semiImplicitEuler updates velocity first and then moves with the new
+// velocity. It is first order but stable for the damped motion the sandbox
+// produces, and needs a single force evaluation per substep.
+type semiImplicitEuler struct {
+	acc []Vec2
+}
+
+func (s *semiImplicitEuler) integrate(b *bodies, accel accelFunc, dt float64) {
+	s.acc = resizeVec2(s.acc, b.len())
+	accel(b.pos, b.vel, s.acc)
+	for i := range b.pos {
+		b.prevPos[i] = b.pos[i]
+		b.vel[i] = b.vel[i].Add(s.acc[i].Scaled(dt))
+		b.pos[i] = b.pos[i].Add(b.vel[i].Scaled(dt))
+	}
+}
+
+func (s *semiImplicitEuler) String() string {
+	return "semi-implicit euler"
+}
+
+// verlet is position Verlet: every body moves to 2x - prev + a·dt², where
+// prev is where it was a substep earlier, and its velocity is read off those
+// positions. It is second order and symplectic, so energy stays bounded on
+// orbits and springs instead of drifting.
+//
+// The previous positions are kept here rather than in bodies, whose prevPos
+// the engine owns for swept collisions and drawing. Collisions and impulses
+// work on velocities, so when a body's velocity no longer matches what was
+// last written, prev is rebuilt from it, and a body that was pushed keeps
+// its previous position at the same offset.
+type verlet struct {
+	prev []Vec2 // position one substep before pos
+	pos  []Vec2 // positions as last written
+	vel  []Vec2 // velocities as last written
+	acc  []Vec2
+	dt   float64 // length of the last substep, zero before the first
+}
+
+func (v *verlet) integrate(b *bodies, accel accelFunc, dt float64) {
+	n := b.len()
+	known := len(v.prev)
+	lastDt := v.dt
+	if lastDt == 0 {
+		known = 0
+		lastDt = dt
+	}
+	v.prev = growVec2(v.prev, n)
+	v.pos = growVec2(v.pos, n)
+	v.vel = growVec2(v.vel, n)
+	v.acc = resizeVec2(v.acc, n)
+	for i := range b.pos {
+		if i >= known || b.vel[i] != v.vel[i] {
+			v.prev[i] = b.pos[i].Sub(b.vel[i].Scaled(lastDt))
+		} else {
+			v.prev[i] = v.prev[i].Add(b.pos[i].Sub(v.pos[i]))
+		}
+		b.prevPos[i] = b.pos[i]
+		// drag and the like see the velocity over the last substep
+		b.vel[i] = b.pos[i].Sub(v.prev[i]).Scaled(1 / lastDt)
+	}
+	accel(b.pos, b.vel, v.acc)
+	for i := range b.pos {
+		// the substep length can change between frames, so the step from
+		// prev is scaled to dt and the acceleration term uses the mean
+		x := b.pos[i]
+		next := x.Add(x.Sub(v.prev[i]).Scaled(dt / lastDt)).Add(v.acc[i].Scaled(dt * (dt + lastDt) * 0.5))
+		v.prev[i] = x
+		b.pos[i] = next
+		// the step from x is the velocity half a substep ago, bring it up
+		// to the end of the substep
+		b.vel[i] = next.Sub(x).Scaled(1 / dt).Add(v.acc[i].Scaled(dt * 0.5))
+		v.pos[i] = next
+		v.vel[i] = b.vel[i]
+	}
+	v.dt = dt
+}
+
+func (v *verlet) String() string {
+	return "verlet"
+}
+
+// rk4 is the classic fourth order Runge-Kutta method. It is the most accurate
+// per substep but evaluates forces four times, and it is not symplectic, so
+// energy slowly leaks over very long runs.
+type rk4 struct {
+	pos, vel           []Vec2 // trial state
+	dx1, dx2, dx3, dx4 []Vec2
+	dv1, dv2, dv3, dv4 []Vec2
+}
+
+func (r *rk4) integrate(b *bodies, accel accelFunc, dt float64) {
+	n := b.len()
+	r.pos = resizeVec2(r.pos, n)
+	r.vel = resizeVec2(r.vel, n)
+	r.dx1 = resizeVec2(r.dx1, n)
+	r.dx2 = resizeVec2(r.dx2, n)
+	r.dx3 = resizeVec2(r.dx3, n)
+	r.dx4 = resizeVec2(r.dx4, n)
+	r.dv1 = resizeVec2(r.dv1, n)
+	r.dv2 = resizeVec2(r.dv2, n)
+	r.dv3 = resizeVec2(r.dv3, n)
+	r.dv4 = resizeVec2(r.dv4, n)
+
+	// k1 at the start
+	copy(r.dx1, b.vel)
+	accel(b.pos, b.vel, r.dv1)
+
+	// k2 at the midpoint using k1
+	r.trial(b, r.dx1, r.dv1, dt*0.5)
+	copy(r.dx2, r.vel)
+	accel(r.pos, r.vel, r.dv2)
+
+	// k3 at the midpoint using k2
+	r.trial(b, r.dx2, r.dv2, dt*0.5)
+	copy(r.dx3, r.vel)
+	accel(r.pos, r.vel, r.dv3)
+
+	// k4 at the end using k3
+	r.trial(b, r.dx3, r.dv3, dt)
+	copy(r.dx4, r.vel)
+	accel(r.pos, r.vel, r.dv4)
+
+	sixthDt := dt / 6
+	for i := range b.pos {
+		b.prevPos[i] = b.pos[i]
+		dx := r.dx1[i].Add(r.dx2[i].Scaled(2)).Add(r.dx3[i].Scaled(2)).Add(r.dx4[i])
+		dv := r.dv1[i].Add(r.dv2[i].Scaled(2)).Add(r.dv3[i].Scaled(2)).Add(r.dv4[i])
+		b.pos[i] = b.pos[i].Add(dx.Scaled(sixthDt))
+		b.vel[i] = b.vel[i].Add(dv.Scaled(sixthDt))
+	}
+}
+
+// trial sets the trial state to the body state advanced by h along dx, dv.
+func (r *rk4) trial(b *bodies, dx, dv []Vec2, h float64) {
+	for i := range b.pos {
+		r.pos[i] = b.pos[i].Add(dx[i].Scaled(h))
+		r.vel[i] = b.vel[i].Add(dv[i].Scaled(h))
+	}
+}
+
+func (r *rk4) String() string {
+	return "rk4"
+}
+
+// copyIntegrator returns an integrator of the same kind as like with its own
+// scratch space, which is dst when that is one already. Verlet's previous
+// positions are state rather than scratch, so they are copied.
+func copyIntegrator(dst, like Integrator) Integrator {
+	switch like := like.(type) {
+	case *verlet:
+		v, ok := dst.(*verlet)
+		if !ok || v == like {
+			v = &verlet{}
+		}
+		v.prev = append(v.prev[:0], like.prev...)
+		v.pos = append(v.pos[:0], like.pos...)
+		v.vel = append(v.vel[:0], like.vel...)
+		v.dt = like.dt
+		return v
+	case *rk4:
+		if r, ok := dst.(*rk4); ok && r != like {
+			return r
+		}
+		return &rk4{}
+	}
+	if s, ok := dst.(*semiImplicitEuler); ok && s != like {
+		return s
+	}
+	return &semiImplicitEuler{}
+}
+
+// cycleIntegrator switches the engine to the next integrator.
+func (e *Engine) cycleIntegrator() {
+	switch e.integrator.(type) {
+	case *semiImplicitEuler:
+		e.integrator = &verlet{}
+	case *verlet:
+		e.integrator = &rk4{}
+	default:
+		e.integrator = &semiImplicitEuler{}
+	}
+}
+
+// growVec2 is resizeVec2 for slices that hold state, it keeps the contents
+// when the slice has to grow.
+func growVec2(s []Vec2, n int) []Vec2 {
+	if cap(s) < n {
+		grown := make([]Vec2, n)
+		copy(grown, s)
+		return grown
+	}
+	return s[:n]
+}
@@ -0,0 +1,83 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+// energyDrift integrates a single body from pos, vel under accel for steps
+// substeps of dt, and returns the largest change in energy seen, relative to
+// the starting energy.
+func energyDrift(integrator Integrator, pos, vel Vec2, accel accelFunc, energy func(pos, vel Vec2) float64, dt float64, steps int) float64 {
+	b := &bodies{
+		pos:     []Vec2{pos},
+		prevPos: []Vec2{pos},
+		vel:     []Vec2{vel},
+	}
+	start := energy(pos, vel)
+	drift := 0.0
+	for i := 0; i < steps; i++ {
+		integrator.integrate(b, accel, dt)
+		drift = math.Max(drift, math.Abs(energy(b.pos[0], b.vel[0])-start))
+	}
+	return drift / math.Abs(start)
+}
+
+func TestIntegratorEnergy(t *testing.T) {
+	// a body on an elliptical orbit around a unit mass at the origin, let go
+	// at its farthest point
+	orbit := func(pos, vel, acc []Vec2) {
+		for i, p := range pos {
+			r := p.Len()
+			acc[i] = p.Scaled(-1 / (r * r * r))
+		}
+	}
+	orbitEnergy := func(pos, vel Vec2) float64 {
+		return vel.Dot(vel)/2 - 1/pos.Len()
+	}
+	// a unit mass on a spring of unit stiffness, stretched by 1
+	spring := func(pos, vel, acc []Vec2) {
+		for i, p := range pos {
+			acc[i] = p.Scaled(-1)
+		}
+	}
+	springEnergy := func(pos, vel Vec2) float64 {
+		return vel.Dot(vel)/2 + pos.Dot(pos)/2
+	}
+
+	const (
+		dt    = 0.01
+		steps = 20000 // about 50 orbits
+		bound = 0.0025
+	)
+	for _, system := range []struct {
+		name     string
+		pos, vel Vec2
+		accel    accelFunc
+		energy   func(pos, vel Vec2) float64
+	}{
+		{"orbit", Vec2{1, 0}, Vec2{0, 0.8}, orbit, orbitEnergy},
+		{"spring", Vec2{1, 0}, Vec2{0, 0}, spring, springEnergy},
+	} {
+		t.Run(system.name, func(t *testing.T) {
+			drift := func(integrator Integrator) float64 {
+				return energyDrift(integrator, system.pos, system.vel, system.accel, system.energy, dt, steps)
+			}
+			eulerDrift := drift(&semiImplicitEuler{})
+			verletDrift := drift(&verlet{})
+			rk4Drift := drift(&rk4{})
+			t.Logf("energy drift: semi-implicit euler %.6f, verlet %.6f, rk4 %.6f", eulerDrift, verletDrift, rk4Drift)
+			if verletDrift > bound {
+				t.Errorf("verlet energy drift %v, want at most %v", verletDrift, bound)
+			}
+			if rk4Drift > bound {
+				t.Errorf("rk4 energy drift %v, want at most %v", rk4Drift, bound)
+			}
+			// semi-implicit euler keeps its error bounded too, but being
+			// first order it should swing further than rk4 at the same dt
+			if eulerDrift <= rk4Drift {
+				t.Errorf("semi-implicit euler energy drift %v, want more than rk4's %v", eulerDrift, rk4Drift)
+			}
+		})
+	}
+}
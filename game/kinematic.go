@@ -0,0 +1,273 @@
+package game
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// PathMode is what a path does when it reaches its last keyframe.
+type PathMode int
+
+const (
+	// PathOnce plays the keyframes once and stays at the last one.
+	PathOnce PathMode = iota
+	// PathPingPong plays the keyframes forwards and then backwards.
+	PathPingPong
+	// PathLoop jumps back to the first keyframe, so it should match the last
+	// for smooth motion.
+	PathLoop
+	// PathSpin ignores keyframes and turns about the pivot at a constant
+	// speed.
+	PathSpin
+)
+
+// Easing shapes the motion between two keyframes.
+type Easing int
+
+const (
+	// EaseLinear moves at a constant speed.
+	EaseLinear Easing = iota
+	// EaseIn starts slow and speeds up.
+	EaseIn
+	// EaseOut starts fast and slows down.
+	EaseOut
+	// EaseInOut speeds up and then slows down.
+	EaseInOut
+)
+
+// apply maps the fraction u of the time between two keyframes to the
+// fraction of the distance covered.
+func (e Easing) apply(u float64) float64 {
+	switch e {
+	case EaseIn:
+		return u * u
+	case EaseOut:
+		return 1 - (1-u)*(1-u)
+	case EaseInOut:
+		return u * u * (3 - 2*u)
+	}
+	return u
+}
+
+// Keyframe is a pose of a kinematic collider, relative to where it was
+// added. Time is in frames.
+type Keyframe struct {
+	Time   float64
+	Offset Vec2
+	Angle  float64 // about the pivot, only capsules rotate
+}
+
+// poser gives a kinematic collider its offset and angle at a time. Paths play
+// back keyframes, other posers can follow input instead.
+type poser interface {
+	pose(t float64) (Vec2, float64)
+}
+
+// Path moves a kinematic collider through keyframes.
+type Path struct {
+	mode   PathMode
+	easing Easing
+	keys   []Keyframe
+	spin   float64 // radians per frame for PathSpin
+}
+
+// NewPath creates a path through keys, which must be in time order.
+func NewPath(mode PathMode, easing Easing, keys ...Keyframe) *Path {
+	return &Path{mode: mode, easing: easing, keys: keys}
+}
+
+// NewSpin creates a path that turns about the pivot at speed radians per
+// frame, for rotating sweepers.
+func NewSpin(speed float64) *Path {
+	return &Path{mode: PathSpin, spin: speed}
+}
+
+// pose returns the offset and angle of the path at time t.
+func (p *Path) pose(t float64) (Vec2, float64) {
+	if p.mode == PathSpin {
+		return Vec2{0, 0}, p.spin * t
+	}
+	if len(p.keys) == 0 {
+		return Vec2{0, 0}, 0
+	}
+	first := p.keys[0]
+	last := p.keys[len(p.keys)-1]
+	duration := last.Time - first.Time
+	if duration <= 0 {
+		return last.Offset, last.Angle
+	}
+	t -= first.Time
+	switch p.mode {
+	case PathOnce:
+		t = clamp(t, 0, duration)
+	case PathLoop:
+		t = math.Mod(t, duration)
+	case PathPingPong:
+		t = math.Mod(t, 2*duration)
+		if t > duration {
+			t = 2*duration - t
+		}
+	}
+	t += first.Time
+	for k := 1; k < len(p.keys); k++ {
+		a := p.keys[k-1]
+		b := p.keys[k]
+		if t > b.Time {
+			continue
+		}
+		u := 0.0
+		if b.Time > a.Time {
+			u = p.easing.apply((t - a.Time) / (b.Time - a.Time))
+		}
+		return a.Offset.Add(a.Offset.To(b.Offset).Scaled(u)), a.Angle + (b.Angle-a.Angle)*u
+	}
+	return last.Offset, last.Angle
+}
+
+// motion is how a collider moved during the last substep, so circles it hits
+// can pick up its velocity.
+type motion struct {
+	velocity Vec2
+	spin     float64 // angular velocity about pivot
+	pivot    Vec2
+}
+
+// at returns the velocity of the point p of the collider.
+func (m motion) at(p Vec2) Vec2 {
+	r := m.pivot.To(p)
+	return m.velocity.Add(Vec2{-r.Y, r.X}.Scaled(m.spin))
+}
+
+func (m motion) moving() bool {
+	return m.velocity != (Vec2{}) || m.spin != 0
+}
+
+// Kinematic moves a capsule or rectangle along a path. It isn't pushed by
+// anything, but pushes circles and hands them its velocity. Rectangles stay
+// axis aligned, so they only follow the offsets.
+type Kinematic struct {
+	path    poser
+	time    float64
+	capsule *Capsule
+	rect    *collisionRect
+
+	// geometry when the collider was added, which the path moves
+	pivot      Vec2
+	start      Vec2
+	end        Vec2
+	upperLeft  Vec2
+	lowerRight Vec2
+}
+
+// addKinematicCapsule adds a capsule that follows path, turning about pivot.
+func (e *Engine) addKinematicCapsule(c *Capsule, path poser, pivot Vec2) *Kinematic {
+	k := &Kinematic{path: path, capsule: c, pivot: pivot, start: c.start, end: c.end}
+	e.capsules = append(e.capsules, c)
+	e.kinematics = append(e.kinematics, k)
+	return k
+}
+
+// addKinematicRect adds a rectangle that follows path.
+func (e *Engine) addKinematicRect(r *collisionRect, path poser) *Kinematic {
+	k := &Kinematic{path: path, rect: r, upperLeft: r.upperLeft, lowerRight: r.lowerRight}
+	e.collisionRects = append(e.collisionRects, r)
+	e.kinematics = append(e.kinematics, k)
+	return k
+}
+
+// moveKinematics advances every kinematic collider by dt and works out its
+// velocity from how far it went.
+func (e *Engine) moveKinematics(dt float64) {
+	if dt == 0 {
+		return
+	}
+	for _, k := range e.kinematics {
+		before, beforeAngle := k.path.pose(k.time)
+		k.time += dt
+		offset, angle := k.path.pose(k.time)
+		m := motion{
+			velocity: before.To(offset).Scaled(1 / dt),
+			pivot:    k.pivot.Add(offset),
+		}
+		if k.capsule != nil {
+			m.spin = (angle - beforeAngle) / dt
+		}
+		k.place(offset, angle, m)
+	}
+}
+
+// place moves the collider to the pose given by offset and angle, moving at
+// m.
+func (k *Kinematic) place(offset Vec2, angle float64, m motion) {
+	if k.capsule != nil {
+		cos, sin := math.Cos(angle), math.Sin(angle)
+		turn := func(p Vec2) Vec2 {
+			r := k.pivot.To(p)
+			return m.pivot.Add(Vec2{r.X*cos - r.Y*sin, r.X*sin + r.Y*cos})
+		}
+		k.capsule.start = turn(k.start)
+		k.capsule.end = turn(k.end)
+		k.capsule.motion = m
+	}
+	if k.rect != nil {
+		k.rect.upperLeft = k.upperLeft.Add(offset)
+		k.rect.lowerRight = k.lowerRight.Add(offset)
+		k.rect.motion = m
+	}
+}
+
+// toggleKinematics adds or removes a demo of a moving platform, a piston and
+// a rotating sweeper.
+func (e *Engine) toggleKinematics(shader *ebiten.Shader) {
+	if len(e.kinematics) > 0 {
+		e.removeKinematics()
+		return
+	}
+	w := e.width
+	h := e.height
+	platform := &collisionRect{upperLeft: Vec2{w * 0.05, h * 0.5}, lowerRight: Vec2{w * 0.15, h*0.5 + 15}}
+	e.addKinematicRect(platform, NewPath(PathPingPong, EaseInOut,
+		Keyframe{Time: 0},
+		Keyframe{Time: 180, Offset: Vec2{w * 0.15, 0}},
+	))
+	piston := NewCapsule(Vec2{w * 0.4, h * 0.02}, Vec2{w * 0.4, h * 0.15}, 12, shader)
+	e.addKinematicCapsule(piston, NewPath(PathLoop, EaseIn,
+		Keyframe{Time: 0},
+		Keyframe{Time: 30, Offset: Vec2{0, h * 0.15}},
+		Keyframe{Time: 120},
+	), piston.start)
+	center := Vec2{w * 0.55, h * 0.8}
+	arm := Vec2{h * 0.15, 0}
+	sweeper := NewCapsule(center.Sub(arm), center.Add(arm), 8, shader)
+	e.addKinematicCapsule(sweeper, NewSpin(0.02), center)
+}
+
+// removeKinematics removes every kinematic collider from the engine.
+func (e *Engine) removeKinematics() {
+	moving := map[interface{}]bool{}
+	for _, k := range e.kinematics {
+		if k.capsule != nil {
+			moving[k.capsule] = true
+		}
+		if k.rect != nil {
+			moving[k.rect] = true
+		}
+	}
+	capsules := e.capsules[:0]
+	for _, c := range e.capsules {
+		if !moving[c] {
+			capsules = append(capsules, c)
+		}
+	}
+	e.capsules = capsules
+	rects := e.collisionRects[:0]
+	for _, r := range e.collisionRects {
+		if !moving[r] {
+			rects = append(rects, r)
+		}
+	}
+	e.collisionRects = rects
+	e.kinematics = e.kinematics[:0]
+	e.deselectCapsule()
+}
@@ -0,0 +1,73 @@
+package game
+
+import (
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/jlafayette/2d-circle-collisions/level"
+)
+
+// Level is static collision geometry traced from a painted bitmap mask.
+type Level struct {
+	chains []*Chain
+	rects  []*collisionRect
+}
+
+// levelChainRadius is the thickness of the traced walls, thin enough that the
+// walls match the painted shapes closely.
+const levelChainRadius = 2
+
+// levelTolerance is how far, in pixels of the mask, simplified walls may
+// stray from the traced ones.
+const levelTolerance = 0.75
+
+// LoadLevel reads a PNG mask where dark pixels are solid, and traces it into
+// collision geometry scaled to fill a world of the given size. Solid shapes
+// that are whole rectangles become rectangle colliders, everything else is
+// outlined with closed chains.
+func LoadLevel(r io.Reader, width, height float64) (*Level, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	m := level.NewMask(img)
+	scale := Vec2{width / float64(m.W), height / float64(m.H)}
+
+	l := &Level{}
+	for _, box := range m.TakeRectangles() {
+		l.rects = append(l.rects, &collisionRect{
+			upperLeft:  Vec2{float64(box.Min.X) * scale.X, float64(box.Min.Y) * scale.Y},
+			lowerRight: Vec2{float64(box.Max.X) * scale.X, float64(box.Max.Y) * scale.Y},
+		})
+	}
+	for _, loop := range m.Trace() {
+		loop = level.SimplifyLoop(loop, levelTolerance)
+		if len(loop) < 3 {
+			continue
+		}
+		points := make([]Vec2, len(loop))
+		for k, p := range loop {
+			points[k] = Vec2{p.X * scale.X, p.Y * scale.Y}
+		}
+		l.chains = append(l.chains, NewChain(points, levelChainRadius, true))
+	}
+	return l, nil
+}
+
+// LoadLevelFile loads the level in the PNG file at path.
+func LoadLevelFile(path string, width, height float64) (*Level, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadLevel(f, width, height)
+}
+
+func (e *Engine) addLevel(l *Level) {
+	e.collisionRects = append(e.collisionRects, l.rects...)
+	for _, c := range l.chains {
+		e.addChain(c)
+	}
+}
@@ -0,0 +1,61 @@
+package game
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestLoadLevel(t *testing.T) {
+	// on a transparent 20 by 20 mask, a solid block and an L shape
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	fill := func(x0, y0, x1, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	fill(2, 2, 6, 4)
+	fill(10, 10, 12, 18)
+	fill(10, 16, 18, 18)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := LoadLevel(&buf, 200, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.rects) != 1 {
+		t.Fatalf("got %v rectangles, want 1", len(l.rects))
+	}
+	if r := l.rects[0]; r.upperLeft != (Vec2{20, 10}) || r.lowerRight != (Vec2{60, 20}) {
+		t.Errorf("rectangle from %v to %v, want from {20 10} to {60 20}", r.upperLeft, r.lowerRight)
+	}
+	if len(l.chains) != 1 {
+		t.Fatalf("got %v chains, want 1", len(l.chains))
+	}
+	c := l.chains[0]
+	if !c.closed {
+		t.Errorf("outline isn't closed")
+	}
+	// the L has six corners, the trace cuts them at 45 degrees
+	if len(c.points) < 6 {
+		t.Errorf("outline has %v points, want at least 6", len(c.points))
+	}
+	for _, p := range c.points {
+		if p.X < 100 || p.X > 180 || p.Y < 50 || p.Y > 90 {
+			t.Errorf("point %v outside the L scaled to the world", p)
+		}
+	}
+}
+
+func TestLoadLevelRejectsNonPNG(t *testing.T) {
+	if _, err := LoadLevel(bytes.NewReader([]byte("not a png")), 200, 100); err == nil {
+		t.Error("loaded a level from garbage")
+	}
+}
@@ -0,0 +1,68 @@
+package game
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// mode is what the game is playing, picked with the function keys.
+type mode int
+
+const (
+	modeSandbox mode = iota
+	modePinball
+	modeBilliards
+)
+
+func (m mode) String() string {
+	switch m {
+	case modeSandbox:
+		return "sandbox"
+	case modePinball:
+		return "pinball"
+	case modeBilliards:
+		return "billiards"
+	}
+	return "unknown"
+}
+
+// modeKeys selects each mode.
+var modeKeys = [...]ebiten.Key{
+	modeSandbox:   ebiten.KeyF1,
+	modePinball:   ebiten.KeyF2,
+	modeBilliards: ebiten.KeyF3,
+}
+
+// scene is a mode other than the sandbox. It has its own engine, input and
+// drawing.
+type scene interface {
+	update(speed float64)
+	draw(screen *ebiten.Image)
+}
+
+// updateMode switches modes when one of their keys is pressed. Scenes are
+// created the first time they are picked and keep their state after that.
+func (g *Game) updateMode() {
+	for m, key := range modeKeys {
+		if inpututil.IsKeyJustPressed(key) {
+			g.mode = mode(m)
+		}
+	}
+	if g.mode == modePinball && g.pinball == nil {
+		g.pinball = newPinball(g.width, g.height, g.circleShader)
+	}
+	if g.mode == modeBilliards && g.billiards == nil {
+		g.billiards = newBilliards(g.width, g.height, g.circleShader)
+	}
+}
+
+// scene returns the scene of the current mode, nil in the sandbox.
+func (g *Game) scene() scene {
+	switch g.mode {
+	case modePinball:
+		return g.pinball
+	case modeBilliards:
+		return g.billiards
+	}
+	return nil
+}
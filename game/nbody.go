@@ -0,0 +1,243 @@
+package game
+
+import (
+	"math"
+	"strconv"
+)
+
+// newBarnesHut creates a disabled n-body gravity solver.
+func newBarnesHut() *barnesHut {
+	return &barnesHut{
+		g:         0.2,
+		theta:     0.5,
+		softening: 5,
+	}
+}
+
+// barnesHut applies mutual gravity between all bodies, with mass taken as the
+// circle area. Instead of summing every pair, bodies are grouped in a
+// quadtree and a distant group is treated as one body at its center of mass,
+// which makes a force evaluation O(n log n). Gravity does not reach across
+// the seams of a wrapping world.
+type barnesHut struct {
+	enabled bool
+	merge   bool // merge circles that touch, for accretion scenes
+
+	g         float64 // gravitational constant
+	theta     float64 // opening angle, 0 sums every pair and larger is faster but rougher
+	softening float64 // added to distances so close encounters don't blow up
+
+	// reused between evaluations
+	nodes []bhNode
+	stack []int
+}
+
+// bhNode is a square of the quadtree.
+type bhNode struct {
+	center   Vec2 // of the square
+	half     float64
+	mass     float64
+	com      Vec2   // center of mass
+	body     int    // the body in a leaf, -1 for internal nodes
+	children [4]int // indices of child nodes, 0 when absent since the root is never a child
+}
+
+// Stop subdividing at this depth so coincident bodies don't recurse forever,
+// the deepest nodes just accumulate everything that lands in them.
+const bhMaxDepth = 32
+
+func (bh *barnesHut) String() string {
+	if !bh.enabled {
+		return "off"
+	}
+	s := "on, theta " + strconv.FormatFloat(bh.theta, 'f', 1, 64)
+	if bh.merge {
+		s += ", merging"
+	}
+	return s
+}
+
+// build the quadtree for the given positions.
+func (bh *barnesHut) build(pos []Vec2, invMass []float64) {
+	bh.nodes = bh.nodes[:0]
+	if len(pos) == 0 {
+		return
+	}
+	low := pos[0]
+	high := pos[0]
+	for _, p := range pos {
+		low = Vec2{math.Min(low.X, p.X), math.Min(low.Y, p.Y)}
+		high = Vec2{math.Max(high.X, p.X), math.Max(high.Y, p.Y)}
+	}
+	half := math.Max(high.X-low.X, high.Y-low.Y)*0.5 + 1
+	bh.nodes = append(bh.nodes, bhNode{
+		center: low.Add(high).Scaled(0.5),
+		half:   half,
+		body:   -1,
+	})
+	for i, p := range pos {
+		if invMass[i] == 0 {
+			continue
+		}
+		bh.insert(0, i, p, 1.0/invMass[i], 0)
+	}
+	// turn the mass weighted sums into centers of mass
+	for n := range bh.nodes {
+		if bh.nodes[n].mass > 0 {
+			bh.nodes[n].com = bh.nodes[n].com.Scaled(1.0 / bh.nodes[n].mass)
+		}
+	}
+}
+
+// insert body i into node n. Nodes are referred to by index because
+// appending to the pool may move it.
+func (bh *barnesHut) insert(n, i int, p Vec2, mass float64, depth int) {
+	node := &bh.nodes[n]
+	if node.mass == 0 {
+		// empty leaf
+		node.body = i
+		node.mass = mass
+		node.com = p.Scaled(mass)
+		return
+	}
+	if depth < bhMaxDepth {
+		if node.body >= 0 {
+			// leaf with a body already, move that one down a level first
+			other := node.body
+			otherMass := node.mass
+			otherPos := node.com.Scaled(1.0 / otherMass)
+			bh.insert(bh.child(n, otherPos), other, otherPos, otherMass, depth+1)
+		}
+		bh.insert(bh.child(n, p), i, p, mass, depth+1)
+	}
+	node = &bh.nodes[n]
+	node.body = -1
+	node.mass += mass
+	node.com = node.com.Add(p.Scaled(mass))
+}
+
+// child returns the index of the child of n that contains p, creating it if
+// needed.
+func (bh *barnesHut) child(n int, p Vec2) int {
+	node := bh.nodes[n]
+	q := 0
+	offset := Vec2{-node.half * 0.5, -node.half * 0.5}
+	if p.X >= node.center.X {
+		q |= 1
+		offset.X = -offset.X
+	}
+	if p.Y >= node.center.Y {
+		q |= 2
+		offset.Y = -offset.Y
+	}
+	if node.children[q] == 0 {
+		bh.nodes = append(bh.nodes, bhNode{
+			center: node.center.Add(offset),
+			half:   node.half * 0.5,
+			body:   -1,
+		})
+		bh.nodes[n].children[q] = len(bh.nodes) - 1
+	}
+	return bh.nodes[n].children[q]
+}
+
+// apply adds the gravitational acceleration of every body to acc.
+func (bh *barnesHut) apply(pos, acc []Vec2, invMass []float64) {
+	bh.build(pos, invMass)
+	if len(bh.nodes) == 0 {
+		return
+	}
+	theta2 := bh.theta * bh.theta
+	eps2 := bh.softening * bh.softening
+	for i, p := range pos {
+		a := Vec2{0, 0}
+		bh.stack = append(bh.stack[:0], 0)
+		for len(bh.stack) > 0 {
+			n := bh.stack[len(bh.stack)-1]
+			bh.stack = bh.stack[:len(bh.stack)-1]
+			node := &bh.nodes[n]
+			if node.mass == 0 || node.body == i {
+				continue
+			}
+			d := p.To(node.com)
+			dist2 := d.Dot(d)
+			size := node.half * 2
+			hasChildren := node.children != [4]int{}
+			if !hasChildren || size*size < theta2*dist2 {
+				// far enough away, or a leaf: treat as a single body
+				if dist2 == 0 {
+					continue
+				}
+				r2 := dist2 + eps2
+				a = a.Add(d.Scaled(bh.g * node.mass / (r2 * math.Sqrt(r2))))
+				continue
+			}
+			for _, c := range node.children {
+				if c != 0 {
+					bh.stack = append(bh.stack, c)
+				}
+			}
+		}
+		acc[i] = acc[i].Add(a)
+	}
+}
+
+// mergeContacts merges every pair of touching circles found in the last
+// substep. The larger circle absorbs the smaller one, keeping the total area
+// and momentum, and the smaller one is removed. Soft body members and fluid
+// particles are left alone.
+func (e *Engine) mergeContacts() {
+	e.merged = e.merged[:0]
+	for _, pair := range e.collidingPairs {
+		a := e.circles[pair.a]
+		b := e.circles[pair.b]
+		if a.hidden || b.hidden || a.merging || b.merging {
+			continue
+		}
+		if a.area < b.area {
+			a, b = b, a
+		}
+		e.absorb(a, b)
+		// mark both so neither takes part in another merge this substep,
+		// indices of the pairs are only valid until the removals below
+		a.merging = true
+		b.merging = true
+		e.merged = append(e.merged, b)
+	}
+	for _, pair := range e.collidingPairs {
+		e.circles[pair.a].merging = false
+		e.circles[pair.b].merging = false
+	}
+	for _, c := range e.merged {
+		e.removeCircle(c)
+	}
+	if len(e.merged) > 0 {
+		// the pairs refer to indices that have moved
+		e.collidingPairs = e.collidingPairs[:0]
+	}
+}
+
+// absorb grows a to contain the area, momentum and charge of b.
+func (e *Engine) absorb(a, b *Circle) {
+	pos := e.bodies.pos
+	vel := e.bodies.vel
+	ma := a.area
+	mb := b.area
+	total := ma + mb
+	i := a.index
+	j := b.index
+	// the merged circle sits at the center of mass, found along the shortest
+	// way between them since they may be touching across a seam
+	pos[i] = pos[i].Add(e.delta(pos[i], pos[j]).Scaled(mb / total))
+	if e.wrap {
+		pos[i] = e.wrapPoint(pos[i])
+	}
+	vel[i] = vel[i].Scaled(ma).Add(vel[j].Scaled(mb)).Scaled(1.0 / total)
+	a.setRadius(math.Sqrt(total / math.Pi))
+	e.setCharge(a, a.charge+b.charge)
+	a.activity = math.Max(a.activity, b.activity) + 0.5
+	e.bodies.radius[i] = a.radius
+	e.bodies.invMass[i] = 1.0 / a.area
+	e.maxRadius = math.Max(e.maxRadius, a.radius)
+	e.maxArea = math.Max(e.maxArea, a.area)
+}
@@ -0,0 +1,18 @@
+package game
+
+import "testing"
+
+func TestAbsorbAcrossSeam(t *testing.T) {
+	e := newTestEngine(0)
+	e.wrap = true
+	a := NewCircle(792, 300, 10, nil)
+	b := NewCircle(12, 300, 10, nil)
+	e.addCircle(a)
+	e.addCircle(b)
+	e.absorb(a, b)
+	// equal areas meet halfway, 2 past the right edge, which wraps to the
+	// left one
+	if got, want := e.bodies.pos[a.index], (Vec2{2, 300}); !near(got, want) {
+		t.Errorf("merged at %v, want %v", got, want)
+	}
+}
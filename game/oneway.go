@@ -0,0 +1,92 @@
+package game
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// oneWaySlop is how far a circle may sink into a one-way face, on top of how
+// far it moved into it, and still be pushed back out.
+const oneWaySlop = 1.0
+
+// oneWayBlocks reports whether a collider with the given one-way normal
+// should push body i out along normal by depth. Only the face along the
+// one-way normal blocks, and only circles that came through it during this
+// substep, so a circle jumping up through a platform isn't popped out of the
+// top when it is half way. How far the circle came through is measured
+// against the collider moving with m at the contact point, so a platform
+// rising into a resting circle still holds it up.
+func (e *Engine) oneWayBlocks(i int, oneWay, normal Vec2, depth float64, m motion, contact Vec2) bool {
+	if oneWay == (Vec2{}) {
+		return true
+	}
+	if normal.Dot(oneWay) <= 0 {
+		return false
+	}
+	moved := e.bodies.prevPos[i].To(e.bodies.pos[i]).Sub(m.at(contact).Scaled(e.stepDt)).Dot(oneWay)
+	return moved <= 0 && depth <= oneWaySlop-moved
+}
+
+// toggleOneWayAt makes the capsule or rectangle under pos only block circles
+// falling onto it from above, or turns that back off. It returns false when
+// there is nothing under pos.
+func (e *Engine) toggleOneWayAt(pos Vec2) bool {
+	up := Vec2{0, -1}
+	c, r := e.colliderAt(pos)
+	switch {
+	case c != nil:
+		if c.oneWay == (Vec2{}) {
+			c.oneWay = up
+		} else {
+			c.oneWay = Vec2{}
+		}
+	case r != nil:
+		if r.oneWay == (Vec2{}) {
+			r.oneWay = up
+		} else {
+			r.oneWay = Vec2{}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// drawOneWayMarks draws a row of ticks along the blocking side of every
+// one-way collider, pointing the way circles are pushed.
+func drawOneWayMarks(screen *ebiten.Image, e *Engine) {
+	clr := colorful.Hcl(30, 0.7, 0.75).Clamped()
+	for _, c := range e.capsules {
+		if c.oneWay == (Vec2{}) {
+			continue
+		}
+		offset := c.oneWay.Scaled(c.radius)
+		drawOneWayTicks(screen, c.start.Add(offset), c.end.Add(offset), c.oneWay, clr)
+	}
+	for _, r := range e.collisionRects {
+		if r.oneWay == (Vec2{}) {
+			continue
+		}
+		// the face of the rectangle that the normal points out of
+		center := r.upperLeft.Add(r.lowerRight).Scaled(0.5)
+		half := r.upperLeft.To(r.lowerRight).Scaled(0.5)
+		n := r.oneWay
+		mid := center.Add(Vec2{n.X * half.X, n.Y * half.Y})
+		along := Vec2{n.Y * half.X, -n.X * half.Y}
+		drawOneWayTicks(screen, mid.Sub(along), mid.Add(along), n, clr)
+	}
+}
+
+// drawOneWayTicks draws short ticks from a to b pointing along normal.
+func drawOneWayTicks(screen *ebiten.Image, a, b, normal Vec2, clr colorful.Color) {
+	const spacing = 12.0
+	length := a.To(b).Len()
+	if length == 0 {
+		return
+	}
+	dir := a.To(b).Scaled(1.0 / length)
+	for d := spacing / 2; d < length; d += spacing {
+		p := a.Add(dir.Scaled(d))
+		drawLine(p, p.Add(normal.Scaled(6)), 2, screen, clr, 0.9)
+	}
+}
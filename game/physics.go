@@ -2,20 +2,31 @@ package game
 
 import (
 	"math"
-	"sort"
 )
 
 // NewEngine initializes a new physics engine
 func NewEngine(width, height int, circles []*Circle, capsules []*Capsule, rectangles []*collisionRect) *Engine {
 
 	e := &Engine{
+		width:           float64(width),
+		height:          float64(height),
 		minArea:         99999999,
-		steps:           10,
-		inverseSteps:    1 / 10,
+		minRadius:       math.MaxFloat64,
+		minSteps:        2,
+		maxSteps:        32,
+		stepTravel:      0.5,
 		selectedCapsule: capsuleSelection{-1, true},
 		capsules:        capsules,
 		collisionRects:  rectangles,
+		integrator:      &semiImplicitEuler{},
+		drag:            defaultLinearDrag,
+		fluid:           newFluid(fluidParticleRadius),
+		nbody:           newBarnesHut(),
+		coulomb:         newCoulomb(),
 	}
+	// Keep the method value around so integrators can call it without
+	// allocating a new closure every substep.
+	e.accel = e.accelerations
 	for _, circle := range circles {
 		e.addCircle(circle)
 	}
@@ -23,26 +34,65 @@ func NewEngine(width, height int, circles []*Circle, capsules []*Capsule, rectan
 }
 
 type collisionRect struct {
-	upperLeft  Vec2
-	lowerRight Vec2
+	upperLeft    Vec2
+	lowerRight   Vec2
+	boundary     bool    // one of the walls around the world, removed when it wraps
+	surfaceSpeed float64 // of the belt around the rectangle, clockwise is positive
+	oneWay       Vec2    // normal of the only face that blocks circles, zero for all of them
+	motion       motion  // set while a path moves the rectangle
 }
 
 // Engine handles collisions
 type Engine struct {
+	width             float64
+	height            float64
+	wrap              bool // circles leaving one edge come back on the opposite one
 	checks            int
+	contacts          int
+	stats             Stats
+	bodies            bodies
+	order             []int // body indices sorted by x position
 	minArea           float64
 	maxArea           float64
+	minRadius         float64
 	maxRadius         float64
 	maxSpeed          float64
+	minSteps          int
+	maxSteps          int
+	stepTravel        float64 // max distance moved per substep, as a fraction of minRadius
 	steps             int
+	substep           int     // substeps of the current frame done, 0 between frames
+	stepDt            float64 // length of each substep of the current frame
+	frames            int     // frames that moved the world forward
+	stepReason        substepReason
 	inverseSteps      float64
+	integrator        Integrator
+	accel             accelFunc
+	drag              Drag // used by bodies that don't set their own
+	gravity           Vec2 // uniform acceleration of every body
 	selectedCircle    circleSelection
 	selectedCapsule   capsuleSelection
 	circles           []*Circle
 	capsules          []*Capsule
 	collisionRects    []*collisionRect
+	arenas            []*Arena
+	chains            []*Chain
+	curves            []*Curve
+	portals           []*Portal
+	kinematics        []*Kinematic
+	selectedChain     chainSelection
+	springs           []spring
+	softBodies        []*SoftBody
+	fluid             *fluid
+	nbody             *barnesHut
+	coulomb           *coulomb
+	merged            []*Circle
+	queried           []int // results of the last area query
 	collidingPairs    []collidingPair
 	collidingCapsules []collidingCapsule
+	walls             []wallContact
+	onTouch           func(a, b *Circle)                // called for every pair of circles that touch, if set
+	onTouchCapsule    func(c *Circle, capsule *Capsule) // called for every circle that touches a capsule, if set
 }
 
 type capsuleSelection struct {
@@ -55,18 +105,91 @@ type circleSelection struct {
 	isDynamic bool
 }
 
+// index returns the body index of the selected circle, or -1.
+func (s circleSelection) index() int {
+	if s.pointer == nil {
+		return -1
+	}
+	return s.pointer.index
+}
+
 func (e *Engine) addCircle(circle *Circle) {
-	for i := range e.circles {
-		if e.circles[i].pos.X == circle.pos.X && e.circles[i].pos.Y == circle.pos.Y {
+	for _, pos := range e.bodies.pos {
+		if pos.X == circle.pos.X && pos.Y == circle.pos.Y {
 			circle.pos.X += 0.1
 			circle.pos.Y += 0.1
 		}
 	}
+	circle.prevPos = circle.pos
+	circle.index = e.bodies.add(circle)
+	e.order = append(e.order, circle.index)
 	e.circles = append(e.circles, circle)
+	if circle.fluid {
+		e.fluid.count++
+	}
+	if circle.charge != 0 {
+		e.coulomb.count++
+	}
+	e.minRadius = math.Min(e.minRadius, circle.radius)
 	e.maxRadius = math.Max(e.maxRadius, circle.radius)
 	e.minArea = math.Min(e.minArea, circle.area)
 	e.maxArea = math.Max(e.maxArea, circle.area)
-	circle.id = len(e.circles)
+}
+
+// measureSizes rescans the bodies for the smallest and largest circle, for
+// when one at either end of the range has gone.
+func (e *Engine) measureSizes() {
+	e.minRadius = math.MaxFloat64
+	e.maxRadius = 0
+	e.minArea = 99999999
+	e.maxArea = 0
+	for _, r := range e.bodies.radius {
+		area := math.Pi * r * r
+		e.minRadius = math.Min(e.minRadius, r)
+		e.maxRadius = math.Max(e.maxRadius, r)
+		e.minArea = math.Min(e.minArea, area)
+		e.maxArea = math.Max(e.maxArea, area)
+	}
+}
+
+// removeCircle takes a circle out of the engine. The last body is moved into
+// its slot, so indices held elsewhere are only valid until this is called.
+func (e *Engine) removeCircle(circle *Circle) {
+	i := circle.index
+	last := len(e.circles) - 1
+	e.bodies.swapRemove(i)
+	e.circles[i] = e.circles[last]
+	e.circles[i].index = i
+	e.circles[last] = nil
+	e.circles = e.circles[:last]
+
+	// drop i from the sorted order and rename the moved body
+	n := 0
+	for _, k := range e.order {
+		if k == i {
+			continue
+		}
+		if k == last {
+			k = i
+		}
+		e.order[n] = k
+		n++
+	}
+	e.order = e.order[:n]
+
+	if e.selectedCircle.pointer == circle {
+		e.selectedCircle.pointer = nil
+	}
+	if circle.fluid {
+		e.fluid.count--
+	}
+	if circle.charge != 0 {
+		e.coulomb.count--
+	}
+	if circle.radius <= e.minRadius || circle.radius >= e.maxRadius {
+		e.measureSizes()
+	}
+	circle.index = -1
 }
 
 func (e *Engine) selectAtPostion(pos Vec2) {
@@ -109,9 +232,9 @@ func (e *Engine) circleNearestPosition(pos Vec2) *Circle {
 	minDistance := math.MaxFloat64
 	var closest *Circle
 	for i := range e.circles {
-		cx := e.circles[i].pos.X
-		cy := e.circles[i].pos.Y
-		cr := e.circles[i].radius
+		cx := e.bodies.pos[i].X
+		cy := e.bodies.pos[i].Y
+		cr := e.bodies.radius[i]
 		d := (cx-pos.X)*(cx-pos.X) + (cy-pos.Y)*(cy-pos.Y)
 		if d < (cr * cr) {
 			return e.circles[i]
@@ -126,9 +249,9 @@ func (e *Engine) circleNearestPosition(pos Vec2) *Circle {
 
 func (e *Engine) circleAtPosition(pos Vec2) *Circle {
 	for i := range e.circles {
-		cx := e.circles[i].pos.X
-		cy := e.circles[i].pos.Y
-		cr := e.circles[i].radius
+		cx := e.bodies.pos[i].X
+		cy := e.bodies.pos[i].Y
+		cr := e.bodies.radius[i]
 		if (cx-pos.X)*(cx-pos.X)+(cy-pos.Y)*(cy-pos.Y) < (cr * cr) {
 			return e.circles[i]
 		}
@@ -139,14 +262,15 @@ func (e *Engine) circleAtPosition(pos Vec2) *Circle {
 func (e *Engine) moveSelectedTo(pos Vec2) {
 	if e.selectedCircle.pointer != nil {
 		e.selectedCircle.pointer.pos = pos
+		e.bodies.pos[e.selectedCircle.pointer.index] = pos
 	}
 }
 
 func (e *Engine) applyForceToSelected(pos Vec2, speed float64) {
 	circle := e.selectedCircle.pointer
 	if circle != nil {
-		force := pos.Sub(circle.pos)
-		circle.acc = force.Scaled(0.03).Scaled(speed)
+		force := pos.Sub(e.bodies.pos[circle.index])
+		e.bodies.impulse[circle.index] = force.Scaled(0.03).Scaled(speed)
 	}
 }
 
@@ -161,9 +285,9 @@ func (e *Engine) dynamicRelease(pos Vec2) {
 	circle := e.selectedCircle.pointer
 	if circle != nil {
 		circle.selected = false
-		force := circle.pos.Sub(pos)
+		force := e.bodies.pos[circle.index].Sub(pos)
 		s := remap(circle.area, e.minArea, e.maxArea, 0.225, 0.04)
-		circle.acc = force.Scaled(s)
+		e.bodies.impulse[circle.index] = force.Scaled(s)
 		circle.activity += force.Len() * 0.1
 	}
 	e.selectedCircle.pointer = nil
@@ -196,7 +320,7 @@ func (e *Engine) getDynamic() *Circle {
 func (e *Engine) selectCapsuleAtPostion(pos Vec2) bool {
 	for i := range e.capsules {
 		v := e.capsules[i].start
-		r := e.circles[i].radius
+		r := e.capsules[i].radius
 		if (v.X-pos.X)*(v.X-pos.X)+(v.Y-pos.Y)*(v.Y-pos.Y) < (r * r) {
 			e.selectedCapsule.index = i
 			e.selectedCapsule.start = true
@@ -228,11 +352,153 @@ func (e *Engine) deselectCapsule() {
 }
 
 func (e *Engine) overlap(i, j int) bool {
+	d := e.delta(e.bodies.pos[i], e.bodies.pos[j])
+	r := e.bodies.radius[i] + e.bodies.radius[j]
+	return d.X*d.X+d.Y*d.Y < r*r
+}
 
-	// This looks ugly, but here it is without all the index lookups
-	// (x1-x2)*(x1-x2)+(y1-y2)*(y1-y2) < (r1+r2)*(r1+r2)
+// delta returns the vector from a to b. When the world wraps around, it is
+// the shortest one, which may cross a seam.
+func (e *Engine) delta(a, b Vec2) Vec2 {
+	d := a.To(b)
+	if e.wrap {
+		if d.X > e.width/2 {
+			d.X -= e.width
+		} else if d.X < -e.width/2 {
+			d.X += e.width
+		}
+		if d.Y > e.height/2 {
+			d.Y -= e.height
+		} else if d.Y < -e.height/2 {
+			d.Y += e.height
+		}
+	}
+	return d
+}
+
+// ghostOffsets appends the offsets at which a copy of a circle at p with
+// radius r has to be drawn so it shows on both sides of a seam.
+func (e *Engine) ghostOffsets(p Vec2, r float64, out []Vec2) []Vec2 {
+	if !e.wrap {
+		return out
+	}
+	dx := 0.0
+	if p.X < r {
+		dx = e.width
+	} else if p.X > e.width-r {
+		dx = -e.width
+	}
+	dy := 0.0
+	if p.Y < r {
+		dy = e.height
+	} else if p.Y > e.height-r {
+		dy = -e.height
+	}
+	if dx != 0 {
+		out = append(out, Vec2{dx, 0})
+	}
+	if dy != 0 {
+		out = append(out, Vec2{0, dy})
+	}
+	if dx != 0 && dy != 0 {
+		out = append(out, Vec2{dx, dy})
+	}
+	return out
+}
 
-	return (e.circles[i].pos.X-e.circles[j].pos.X)*(e.circles[i].pos.X-e.circles[j].pos.X)+(e.circles[i].pos.Y-e.circles[j].pos.Y)*(e.circles[i].pos.Y-e.circles[j].pos.Y) < (e.circles[i].radius+e.circles[j].radius)*(e.circles[i].radius+e.circles[j].radius)
+// wrapPositions moves circles that left the world back in from the opposite
+// edge.
+func (e *Engine) wrapPositions() {
+	for i, p := range e.bodies.pos {
+		if p.X < 0 || p.X >= e.width || p.Y < 0 || p.Y >= e.height {
+			e.bodies.pos[i] = e.wrapPoint(p)
+		}
+	}
+}
+
+// wrapPoint returns p moved into the world from the opposite edge.
+func (e *Engine) wrapPoint(p Vec2) Vec2 {
+	p.X = math.Mod(p.X, e.width)
+	if p.X < 0 {
+		p.X += e.width
+	}
+	p.Y = math.Mod(p.Y, e.height)
+	if p.Y < 0 {
+		p.Y += e.height
+	}
+	return p
+}
+
+// substepReason records why the engine picked the current substep count.
+type substepReason int
+
+const (
+	substepsIdle substepReason = iota
+	substepsCalm
+	substepsVelocity
+	substepsCapped
+	substepsFluid
+)
+
+func (r substepReason) String() string {
+	switch r {
+	case substepsIdle:
+		return "idle"
+	case substepsCalm:
+		return "calm"
+	case substepsVelocity:
+		return "velocity"
+	case substepsCapped:
+		return "capped"
+	case substepsFluid:
+		return "fluid"
+	}
+	return "unknown"
+}
+
+// chooseSteps picks the substep count for the coming frame so that the
+// fastest circle moves at most stepTravel of the smallest radius per substep,
+// within minSteps and maxSteps.
+func (e *Engine) chooseSteps(speed, elapsedTime float64) {
+	maxDisplacement := 0.0
+	for i := range e.bodies.vel {
+		// include pending impulses so a slingshot release is caught on the
+		// frame it happens
+		v := e.bodies.vel[i].Add(e.bodies.impulse[i])
+		maxDisplacement = math.Max(maxDisplacement, v.Len())
+	}
+	maxDisplacement *= speed * elapsedTime
+
+	if len(e.circles) == 0 || maxDisplacement == 0 {
+		e.steps = e.minSteps
+		e.stepReason = substepsIdle
+	} else {
+		want := int(math.Ceil(maxDisplacement / (e.minRadius * e.stepTravel)))
+		if want <= e.minSteps {
+			e.steps = e.minSteps
+			e.stepReason = substepsCalm
+		} else if want > e.maxSteps {
+			e.steps = e.maxSteps
+			e.stepReason = substepsCapped
+		} else {
+			e.steps = want
+			e.stepReason = substepsVelocity
+		}
+	}
+	if e.fluid.count > 0 {
+		// pressure waves travel at the speed of sound, keep them from crossing
+		// more than 0.4 smoothing radii per substep
+		want := int(math.Ceil(speed * elapsedTime * math.Sqrt(e.fluid.stiffness) / (0.4 * e.fluid.h)))
+		if want > e.steps {
+			e.steps = want
+			e.stepReason = substepsFluid
+			if e.steps > e.maxSteps {
+				e.steps = e.maxSteps
+				e.stepReason = substepsCapped
+			}
+		}
+	}
+	e.inverseSteps = 1.0 / float64(e.steps)
 }
 
 type collidingPair struct {
@@ -247,99 +513,279 @@ type collidingCapsule struct {
 	pos Vec2
 }
 
+// update moves the world forward by speed * elapsedTime. A frame that was
+// started by stepSubstep is finished instead.
 func (e *Engine) update(width, height int, speed, elapsedTime float64) {
+	if e.substep == 0 {
+		e.startFrame(speed, elapsedTime)
+	}
+	for e.substep < e.steps {
+		e.advanceSubstep()
+	}
+	e.finishFrame()
+}
+
+// stepSubstep runs a single substep, starting a frame first if none is in
+// progress and finishing it after its last substep, so the collision
+// resolution can be watched one substep at a time.
+func (e *Engine) stepSubstep(speed, elapsedTime float64) {
+	if e.substep == 0 {
+		e.startFrame(speed, elapsedTime)
+	}
+	e.advanceSubstep()
+	if e.substep == e.steps {
+		e.finishFrame()
+		return
+	}
+	for i, circle := range e.circles {
+		circle.prevPos = circle.pos
+		circle.pos = e.bodies.pos[i]
+		circle.vel = e.bodies.vel[i]
+	}
+}
+
+// midFrame reports whether stepSubstep left a frame unfinished.
+func (e *Engine) midFrame() bool {
+	return e.substep > 0
+}
+
+// startFrame picks the substeps of a frame that moves the world forward by
+// speed * elapsedTime.
+func (e *Engine) startFrame(speed, elapsedTime float64) {
 	e.checks = 0
+	e.contacts = 0
+	e.stats.MaxPenetration = 0
 
-	// set previous position
-	for i := range e.circles {
-		e.circles[i].prevPos = e.circles[i].pos
+	e.chooseSteps(speed, elapsedTime)
+	e.stepDt = speed * elapsedTime / float64(e.steps)
+}
+
+// advanceSubstep runs the next substep of the current frame.
+func (e *Engine) advanceSubstep() {
+	dt := e.stepDt
+	e.moveKinematics(dt)
+	e.updateCirclePositions(dt)
+	e.sortCircles()
+	e.resolveStaticCollisions()
+	e.resolveDynamicCollisions()
+	if e.nbody.enabled && e.nbody.merge {
+		e.mergeContacts()
 	}
+	e.substep++
+}
 
-	stepSpeed := speed / float64(e.steps)
-	for step := e.steps; step > 0; step-- {
-		e.updateCirclePositions(width, height, stepSpeed, elapsedTime)
-		e.sortCircles()
-		e.resolveStaticCollisions()
-		e.resolveDynamicCollisions()
+// finishFrame copies the state of the bodies back to the circles for drawing
+// after the last substep of a frame.
+func (e *Engine) finishFrame() {
+	e.substep = 0
+	if e.stepDt > 0 {
+		e.frames++
 	}
 
-	// find max speed
+	// copy state back to the circles for drawing and find max speed
 	e.maxSpeed = 0
-	for i := range e.circles {
-		e.circles[i].postUpdate()
-		e.maxSpeed = math.Max(e.maxSpeed, e.circles[i].speed)
+	for i, circle := range e.circles {
+		circle.prevPos = circle.pos
+		circle.pos = e.bodies.pos[i]
+		if e.wrap && circle.prevPos.To(circle.pos).Len() > math.Min(e.width, e.height)/2 {
+			// went through a seam, don't smear motion blur across the world
+			circle.prevPos = circle.pos
+		}
+		circle.vel = e.bodies.vel[i]
+		circle.addCollisionEnergy(e.bodies.energy[i])
+		circle.postUpdate()
+		e.bodies.energy[i] = 0
+		e.bodies.speed[i] = circle.speed
+		e.maxSpeed = math.Max(e.maxSpeed, circle.speed)
 	}
+	e.measure()
 }
 
-func (e *Engine) updateCirclePositions(width, height int, speed, elapsedTime float64) {
-	// Update ball positions
-	for i := range e.circles {
-
-		// apply friction
-		frictionAmount := remap(e.circles[i].area, e.minArea, e.maxArea, 0.015, 0.007)
-		friction := e.circles[i].acc.Sub(e.circles[i].vel.Scaled(frictionAmount).Scaled(speed))
+func (e *Engine) updateCirclePositions(dt float64) {
+	// apply pending impulses
+	vel := e.bodies.vel
+	impulse := e.bodies.impulse
+	for i := range vel {
+		vel[i] = vel[i].Add(impulse[i])
+		impulse[i] = Vec2{0, 0}
+	}
 
-		// update velocity and position
-		e.circles[i].vel = e.circles[i].vel.Add(friction)
+	if e.fluid.count > 0 || e.coulomb.active() {
+		e.sortCircles()
+	}
+	if e.fluid.count > 0 {
+		e.fluid.findNeighbors(e)
+	}
+	if e.coulomb.active() {
+		e.coulomb.findNeighbors(e)
+	}
 
-		posChange := e.circles[i].vel.Scaled(elapsedTime).Scaled(speed)
-		e.circles[i].pos = e.circles[i].pos.Add(posChange)
+	e.integrator.integrate(&e.bodies, e.accel, dt)
+	if e.wrap {
+		e.wrapPositions()
+	}
+	if len(e.portals) > 0 {
+		e.teleport()
+	}
+}
 
-		e.circles[i].acc = Vec2{0, 0}
+// accelerations writes the acceleration of every body into acc for the given
+// positions and velocities. Integrators may call it several times per substep
+// with trial states, so it must not touch the engine's body storage.
+func (e *Engine) accelerations(pos, vel, acc []Vec2) {
+	drag := e.bodies.drag
+	for i := range acc {
+		// apply drag, bodies without their own setting use the world's
+		d := drag[i]
+		if d.Model == DragWorld {
+			d = e.drag
+		}
+		acc[i] = d.accel(vel[i]).Add(e.gravity)
+	}
+	e.applySprings(pos, vel, acc)
+	for _, sb := range e.softBodies {
+		sb.applyPressure(e, pos, acc)
+	}
+	if e.fluid.count > 0 {
+		e.fluid.apply(e, pos, vel, acc)
+	}
+	if e.nbody.enabled {
+		e.nbody.apply(pos, acc, e.bodies.invMass)
+	}
+	if e.coulomb.active() {
+		e.coulomb.apply(e, pos, acc)
 	}
 }
 
 func (e *Engine) sortCircles() {
-	// sort by x position
-	sort.Slice(e.circles, func(i, j int) bool {
-		return e.circles[i].pos.X < e.circles[j].pos.X
-	})
+	// Insertion sort of body indices by x position. Circles only move a little
+	// each substep so the order is nearly sorted already, which keeps this
+	// close to linear, and unlike sort.Slice it doesn't allocate.
+	pos := e.bodies.pos
+	order := e.order
+	for i := 1; i < len(order); i++ {
+		k := order[i]
+		x := pos[k].X
+		j := i - 1
+		for ; j >= 0 && pos[order[j]].X > x; j-- {
+			order[j+1] = order[j]
+		}
+		order[j+1] = k
+	}
+}
+
+// neighborPairs appends every pair of bodies with centers closer than reach
+// to pairs. It uses the same sweep over the sorted order as the collision
+// broadphase, so the order must be sorted first.
+func (e *Engine) neighborPairs(reach float64, pairs []collidingPair) []collidingPair {
+	pos := e.bodies.pos
+	reach2 := reach * reach
+	for oi, i := range e.order {
+		for _, j := range e.order[oi+1:] {
+			if pos[j].X-pos[i].X > reach {
+				break
+			}
+			d := e.delta(pos[i], pos[j])
+			if d.Dot(d) < reach2 {
+				pairs = append(pairs, collidingPair{i, j})
+			}
+		}
+		if e.wrap {
+			// pairs across the left and right seam
+			limit := pos[i].X + reach - e.width
+			for _, j := range e.order {
+				if pos[j].X > limit || j == i {
+					break
+				}
+				d := e.delta(pos[i], pos[j])
+				if d.Dot(d) < reach2 {
+					pairs = append(pairs, collidingPair{i, j})
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// resolvePair records a colliding pair and pushes the circles apart.
+func (e *Engine) resolvePair(i, j, selected int) {
+	pos := e.bodies.pos
+	radius := e.bodies.radius
+	invMass := e.bodies.invMass
+	speed := e.bodies.speed
+	energy := e.bodies.energy
+
+	e.collidingPairs = append(e.collidingPairs, collidingPair{i, j})
+	if e.onTouch != nil {
+		e.onTouch(e.circles[i], e.circles[j])
+	}
+	// distance between ball centers
+	r1 := radius[i]
+	r2 := radius[j]
+	v := e.delta(pos[j], pos[i])
+	distance := v.Len()
+	e.addContact(r1 + r2 - distance)
+	unit := v.Scaled(1.0 / distance)
+	if i == selected {
+		// displace target circle away from collision
+		amount := distance - r1 - r2
+		pos[j] = pos[j].Add(unit.Scaled(amount))
+	} else {
+		// Make displace amount depend on mass, the lighter circle
+		// moves further
+		totalAmount := distance - r1 - r2
+		invMassSumM := 1.0 / (invMass[i] + invMass[j])
+		amount1 := totalAmount * invMass[i] * invMassSumM
+		amount2 := totalAmount * invMass[j] * invMassSumM
+		// displace current circle away from the collision
+		pos[i] = pos[i].Sub(unit.Scaled(amount1))
+		// displace target circle away from collision
+		pos[j] = pos[j].Add(unit.Scaled(amount2))
+
+		// boose circle activity based on speed of collision
+		collisionEnergy := speed[i] + speed[j]
+		energy[i] += collisionEnergy * e.inverseSteps
+		energy[j] += collisionEnergy * e.inverseSteps
+	}
 }
 
 func (e *Engine) resolveStaticCollisions() {
 	// Resolve static collisions
 	e.collidingPairs = e.collidingPairs[:0]       // clear slice but keep capacity
 	e.collidingCapsules = e.collidingCapsules[:0] // clear slice but keep capacity
+	e.walls = e.walls[:0]
 
-	for i := range e.circles {
-		for j := i + 1; j < len(e.circles); j++ {
+	pos := e.bodies.pos
+	vel := e.bodies.vel
+	radius := e.bodies.radius
+	isFluid := e.bodies.fluid
+	selected := e.selectedCircle.index()
+
+	for oi, i := range e.order {
+		for _, j := range e.order[oi+1:] {
 			e.checks++
-			if e.overlap(i, j) {
-				e.collidingPairs = append(e.collidingPairs, collidingPair{i, j})
-				// distance between ball centers
-				r1 := e.circles[i].radius
-				r2 := e.circles[j].radius
-				v := e.circles[i].pos.Sub(e.circles[j].pos)
-				distance := v.Len()
-				unit := v.Scaled(1.0 / distance)
-				if e.selectedCircle.pointer != nil && e.circles[i].id == e.selectedCircle.pointer.id {
-					// displace target circle away from collision
-					amount := distance - r1 - r2
-					e.circles[j].pos = e.circles[j].pos.Add(unit.Scaled(amount))
-				} else {
-					// Make displace amount depend on area
-					totalAmount := distance - r1 - r2
-					a1 := e.circles[i].area
-					a2 := e.circles[j].area
-					areaSumM := 1.0 / (a1 + a2)
-					amount1 := totalAmount * a2 * areaSumM
-					amount2 := totalAmount * a1 * areaSumM
-					// displace current circle away from the collision
-					e.circles[i].pos = e.circles[i].pos.Sub(unit.Scaled(amount1))
-					// displace target circle away from collision
-					e.circles[j].pos = e.circles[j].pos.Add(unit.Scaled(amount2))
-
-					// boose circle activity based on speed of collision
-					energy := e.circles[i].speed + e.circles[j].speed
-					e.circles[i].addCollisionEnergy(energy * e.inverseSteps)
-					e.circles[j].addCollisionEnergy(energy * e.inverseSteps)
-				}
+			// fluid particles push each other apart with pressure instead
+			if !(isFluid[i] && isFluid[j]) && e.overlap(i, j) {
+				e.resolvePair(i, j, selected)
 			} else {
-				if e.circles[j].pos.X > e.circles[i].pos.X+e.circles[i].radius+e.maxRadius {
+				if pos[j].X > pos[i].X+radius[i]+e.maxRadius {
 					break
 				}
 			}
 		}
+		if e.wrap {
+			// circles near the right edge can touch ones near the left edge
+			reach := pos[i].X + radius[i] + e.maxRadius - e.width
+			for _, j := range e.order {
+				if pos[j].X > reach {
+					break
+				}
+				e.checks++
+				if j != i && !(isFluid[i] && isFluid[j]) && e.overlap(i, j) {
+					e.resolvePair(i, j, selected)
+				}
+			}
+		}
 
 		// line collisions
 		for j := range e.capsules {
@@ -348,9 +794,9 @@ func (e *Engine) resolveStaticCollisions() {
 			lx2 := e.capsules[j].end.X
 			ly2 := e.capsules[j].end.Y
 			lr := e.capsules[j].radius
-			cx := e.circles[i].pos.X
-			cy := e.circles[i].pos.Y
-			cr := e.circles[i].radius
+			cx := pos[i].X
+			cy := pos[i].Y
+			cr := radius[i]
 			// Line vector
 			lineX1 := lx2 - lx1
 			lineY1 := ly2 - ly1
@@ -362,7 +808,11 @@ func (e *Engine) resolveStaticCollisions() {
 
 			// t represents the closest point on the line segment, normalized between 0 and 1
 			// where zero is the start, and one is end of the line.
-			t := math.Max(0, math.Min(lineLen, (lineX1*lineX2+lineY1*lineY2))) / lineLen
+			// A capsule with both ends in the same place is a round post.
+			t := 0.0
+			if lineLen > 0 {
+				t = math.Max(0, math.Min(lineLen, (lineX1*lineX2+lineY1*lineY2))) / lineLen
+			}
 
 			// Closest point
 			closestPointX := lx1 + t*lineX1
@@ -373,18 +823,39 @@ func (e *Engine) resolveStaticCollisions() {
 
 			// Check for collision
 			if dist <= (cr + lr) {
-				e.collidingCapsules = append(
-					e.collidingCapsules,
-					collidingCapsule{i, lr, dist, Vec2{closestPointX, closestPointY}},
-				)
-
 				// Calculate displacement required
 				amount := dist - cr - lr
+				distanceM := 1.0 / dist // Can be used to multiply instead of divide by dist
+				normal := Vec2{(cx - closestPointX) * distanceM, (cy - closestPointY) * distanceM}
+				if !e.oneWayBlocks(i, e.capsules[j].oneWay, normal, -amount, e.capsules[j].motion, Vec2{closestPointX, closestPointY}) {
+					continue
+				}
+				e.addContact(-amount)
+				if e.onTouchCapsule != nil {
+					e.onTouchCapsule(e.circles[i], e.capsules[j])
+				}
 
 				// displace circle away from collision
-				distanceM := 1.0 / dist // Can be used to multiply instead of divide by dist
-				e.circles[i].pos.X -= amount * (cx - closestPointX) * distanceM
-				e.circles[i].pos.Y -= amount * (cy - closestPointY) * distanceM
+				pos[i].X -= amount * normal.X
+				pos[i].Y -= amount * normal.Y
+
+				if speed, m := e.capsules[j].surfaceSpeed, e.capsules[j].motion; speed != 0 || m.moving() {
+					// moving belts and capsules bounce and carry circles
+					// along instead
+					closest := Vec2{closestPointX, closestPointY}
+					e.walls = append(e.walls, wallContact{
+						i:           i,
+						normal:      normal,
+						velocity:    surfaceVelocity(normal, speed).Add(m.at(closest)),
+						restitution: 1,
+						grip:        surfaceGrip,
+					})
+					continue
+				}
+				e.collidingCapsules = append(
+					e.collidingCapsules,
+					collidingCapsule{i, lr, dist, Vec2{closestPointX, closestPointY}},
+				)
 
 				// TODO: Add ball and line pair to dynamic collisions
 			}
@@ -392,15 +863,18 @@ func (e *Engine) resolveStaticCollisions() {
 
 		// Rectangle collisions
 		for j := range e.collisionRects {
+			if e.wrap && e.collisionRects[j].boundary {
+				continue
+			}
 			upperLeft := e.collisionRects[j].upperLeft
 			lowerRight := e.collisionRects[j].lowerRight
 			// nearest point
-			x := clamp(e.circles[i].pos.X, upperLeft.X, lowerRight.X)
-			y := clamp(e.circles[i].pos.Y, upperLeft.Y, lowerRight.Y)
+			x := clamp(pos[i].X, upperLeft.X, lowerRight.X)
+			y := clamp(pos[i].Y, upperLeft.Y, lowerRight.Y)
 			nearest := Vec2{x, y}
-			v := e.circles[i].pos.To(nearest)
+			v := pos[i].To(nearest)
 			dist := v.Len()
-			if dist < e.circles[i].radius {
+			if dist < radius[i] {
 
 				// If circle is mostly inside, push nearest point out to nearest edge
 				// TODO: Move this to dynamic collision resolution section
@@ -408,73 +882,135 @@ func (e *Engine) resolveStaticCollisions() {
 				dBt := math.Abs(lowerRight.Y - y)
 				dLf := math.Abs(upperLeft.X - x)
 				dRt := math.Abs(lowerRight.X - x)
+				var normal Vec2
+				var edge float64 // distance from the center to the edge
 				if dTp <= dBt && dTp <= dLf && dTp <= dRt {
 					y = upperLeft.Y
-					e.circles[i].vel.Y = -e.circles[i].vel.Y
+					normal = Vec2{0, -1}
+					edge = dTp
 				} else if dBt <= dTp && dBt <= dLf && dBt <= dRt {
 					y = lowerRight.Y
-					e.circles[i].vel.Y = -e.circles[i].vel.Y
+					normal = Vec2{0, 1}
+					edge = dBt
 				} else if dLf <= dTp && dLf <= dBt && dLf <= dRt {
 					x = upperLeft.X
-					e.circles[i].vel.X = -e.circles[i].vel.X
+					normal = Vec2{-1, 0}
+					edge = dLf
 				} else if dRt <= dTp && dRt <= dBt && dRt <= dLf {
 					x = lowerRight.X
-					e.circles[i].vel.X = -e.circles[i].vel.X
+					normal = Vec2{1, 0}
+					edge = dRt
 				} else {
 					x = lowerRight.X
-					e.circles[i].vel.X = -e.circles[i].vel.X
+					normal = Vec2{1, 0}
+					edge = dRt
+				}
+				depth := radius[i] - dist
+				if dist == 0 {
+					depth = edge + radius[i]
+				}
+				if !e.oneWayBlocks(i, e.collisionRects[j].oneWay, normal, depth, e.collisionRects[j].motion, Vec2{x, y}) {
+					continue
+				}
+				if m := e.collisionRects[j].motion; m.moving() {
+					// bounce off the moving rectangle in its own frame
+					e.walls = append(e.walls, wallContact{
+						i:           i,
+						normal:      normal,
+						velocity:    surfaceVelocity(normal, e.collisionRects[j].surfaceSpeed).Add(m.velocity),
+						restitution: 1,
+						grip:        surfaceGrip,
+					})
+				} else {
+					if normal.X != 0 {
+						vel[i].X = -vel[i].X
+					} else {
+						vel[i].Y = -vel[i].Y
+					}
+					if speed := e.collisionRects[j].surfaceSpeed; speed != 0 {
+						// the bounce is done above, just let the belt carry it
+						e.walls = append(e.walls, wallContact{
+							i:        i,
+							normal:   normal,
+							velocity: surfaceVelocity(normal, speed),
+							grip:     surfaceGrip,
+						})
+					}
 				}
 
 				if dist > 0 {
 					// Circle is mostly outside
 
 					// Calculate displacement required
-					amount := dist - e.circles[i].radius
+					amount := dist - radius[i]
+					e.addContact(-amount)
 					// displace circle away from collision
-					e.circles[i].pos = e.circles[i].pos.Add(v.Unit().Scaled(amount))
+					pos[i] = pos[i].Add(v.Unit().Scaled(amount))
 				} else {
 					nearest = Vec2{x, y}
-					v = e.circles[i].pos.To(nearest)
+					v = pos[i].To(nearest)
 					dist = v.Len()
 					// Calculate displacement required
-					amount := dist + e.circles[i].radius
+					amount := dist + radius[i]
+					e.addContact(amount)
 					// displace circle away from collision
-					e.circles[i].pos = e.circles[i].pos.Add(v.Unit().Scaled(amount))
+					pos[i] = pos[i].Add(v.Unit().Scaled(amount))
 				}
 			}
 		}
+
+		// Chain collisions
+		for _, c := range e.chains {
+			e.resolveChain(c, i)
+		}
+
+		// Curve collisions
+		for _, c := range e.curves {
+			e.resolveCurve(c, i)
+		}
+
+		// Arena collisions
+		for _, a := range e.arenas {
+			e.resolveArena(a, i)
+		}
 	}
 }
 
 func (e *Engine) resolveDynamicCollisions() {
+	pos := e.bodies.pos
+	vel := e.bodies.vel
+	invMass := e.bodies.invMass
+
 	// dynamic collisions
 	for _, cap := range e.collidingCapsules {
-		a1 := e.circles[cap.i].area
-		v2 := e.circles[cap.i].vel.Scaled(-1.0)
-		a2 := a1
+		v2 := vel[cap.i].Scaled(-1.0)
 
 		// Normalized
-		nV := e.circles[cap.i].pos.To(cap.pos).Unit()
+		nV := pos[cap.i].To(cap.pos).Unit()
 
-		// Calculate new velocities from elastic collision
+		// Calculate new velocities from elastic collision against a mirror
+		// image of the circle
 		// https://en.wikipedia.org/wiki/Elastic_collision
-		kV := e.circles[cap.i].vel.Sub(v2)
-		p := 2.0 * nV.Dot(kV) / (a1 + a2)
-		e.circles[cap.i].vel = e.circles[cap.i].vel.Sub(nV.Scaled(p).Scaled(a2))
+		kV := vel[cap.i].Sub(v2)
+		p := nV.Dot(kV)
+		vel[cap.i] = vel[cap.i].Sub(nV.Scaled(p))
 	}
 
+	e.bounceOffWalls()
+
 	for _, pair := range e.collidingPairs {
-		a1 := e.circles[pair.a].area
-		a2 := e.circles[pair.b].area
+		im1 := invMass[pair.a]
+		im2 := invMass[pair.b]
 
 		// Normalized
-		nV := e.circles[pair.a].pos.To(e.circles[pair.b].pos).Unit()
+		nV := e.delta(pos[pair.a], pos[pair.b]).Unit()
 
-		// Calculate new velocities from elastic collision
+		// Calculate new velocities from elastic collision, written in terms of
+		// inverse mass: m2 / (m1 + m2) == im1 / (im1 + im2)
 		// https://en.wikipedia.org/wiki/Elastic_collision
-		kV := e.circles[pair.a].vel.Sub(e.circles[pair.b].vel)
-		p := 2.0 * nV.Dot(kV) / (a1 + a2)
-		e.circles[pair.a].vel = e.circles[pair.a].vel.Sub(nV.Scaled(p).Scaled(a2))
-		e.circles[pair.b].vel = e.circles[pair.b].vel.Add(nV.Scaled(p).Scaled(a1))
+		kV := vel[pair.a].Sub(vel[pair.b])
+		p := 2.0 * nV.Dot(kV) / (im1 + im2)
+		vel[pair.a] = vel[pair.a].Sub(nV.Scaled(p).Scaled(im1))
+		vel[pair.b] = vel[pair.b].Add(nV.Scaled(p).Scaled(im2))
 	}
 }
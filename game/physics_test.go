@@ -0,0 +1,87 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// newTestEngine fills a headless engine with circles resting on a floor and
+// a few capsules, the way the sandbox starts out.
+func newTestEngine(circles int) *Engine {
+	rand.Seed(1)
+	e := NewEngine(800, 600, nil, nil, nil)
+	e.gravity = Vec2{0, 0.2}
+	e.capsules = append(e.capsules,
+		NewCapsule(Vec2{100, 450}, Vec2{400, 500}, 8, nil),
+		NewCapsule(Vec2{700, 300}, Vec2{450, 350}, 8, nil),
+	)
+	for i := 0; i < circles; i++ {
+		c := NewCircle(randFloat(20, 780), randFloat(20, 400), randFloat(5, 15), nil)
+		c.vel = Vec2{randFloat(-3, 3), randFloat(-3, 3)}
+		e.addCircle(c)
+	}
+	return e
+}
+
+func TestEngineUpdateDoesNotAllocate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(e *Engine)
+	}{
+		{"collisions", func(e *Engine) {}},
+		{"merging n-body", func(e *Engine) {
+			e.nbody.enabled = true
+			e.nbody.merge = true
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestEngine(200)
+			tc.setup(e)
+			// let scratch buffers grow to their working size first
+			for i := 0; i < 60; i++ {
+				e.update(800, 600, 1, 1)
+			}
+			allocs := testing.AllocsPerRun(60, func() {
+				e.update(800, 600, 1, 1)
+			})
+			if allocs != 0 {
+				t.Errorf("update allocated %v times per run, want 0", allocs)
+			}
+		})
+	}
+}
+
+func BenchmarkEngineUpdate(b *testing.B) {
+	e := newTestEngine(500)
+	for i := 0; i < 60; i++ {
+		e.update(800, 600, 1, 1)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.update(800, 600, 1, 1)
+	}
+}
+
+func TestStepSubstepMatchesUpdate(t *testing.T) {
+	whole := newTestEngine(100)
+	stepped := newTestEngine(100)
+	for frame := 0; frame < 30; frame++ {
+		whole.update(800, 600, 1, 1)
+		stepped.stepSubstep(1, 1)
+		steps := 1
+		for stepped.midFrame() {
+			stepped.stepSubstep(1, 1)
+			steps++
+		}
+		if steps != whole.steps {
+			t.Fatalf("frame %v took %v substeps, want %v", frame, steps, whole.steps)
+		}
+		for i, c := range whole.circles {
+			if stepped.circles[i].pos != c.pos || stepped.circles[i].vel != c.vel {
+				t.Fatalf("frame %v: circle %v at %v moving %v, want %v moving %v",
+					frame, i, stepped.circles[i].pos, stepped.circles[i].vel, c.pos, c.vel)
+			}
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package game
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+const (
+	pinballBalls  = 3
+	flipperSpeed  = 0.25 // radians per frame
+	bumperKick    = 6.0  // speed a bumper adds to the ball
+	bumperScore   = 100
+	bumperFlash   = 12   // frames a bumper stays lit, and won't kick again
+	plungerFrames = 45.0 // frames to pull the plunger all the way back
+	plungerSpeed  = 30.0 // launch speed at full charge
+)
+
+// flipper turns a capsule about its pivot while one of its keys is held. It
+// drives the capsule as a kinematic path, so the ball picks up the velocity
+// of the point of the flipper it touches.
+type flipper struct {
+	keys      []ebiten.Key
+	raised    float64 // angle from rest when held, negative is anticlockwise on screen
+	kinematic *Kinematic
+	held      bool
+	from      float64 // angle when held last changed
+	since     float64 // path time when held last changed
+}
+
+// setHeld presses or lets go of the flipper at path time t.
+func (f *flipper) setHeld(held bool, t float64) {
+	if held == f.held {
+		return
+	}
+	f.from = f.angle(t)
+	f.since = t
+	f.held = held
+}
+
+// angle turns the flipper from where it was when it was last pressed or let
+// go towards its raised or resting angle at flipperSpeed.
+func (f *flipper) angle(t float64) float64 {
+	target := 0.0
+	if f.held {
+		target = f.raised
+	}
+	step := flipperSpeed * math.Max(t-f.since, 0)
+	if f.from < target {
+		return math.Min(f.from+step, target)
+	}
+	return math.Max(f.from-step, target)
+}
+
+func (f *flipper) pose(t float64) (Vec2, float64) {
+	return Vec2{0, 0}, f.angle(t)
+}
+
+// bumper is a round post that kicks the ball away and scores when hit.
+type bumper struct {
+	capsule *Capsule
+	flash   int
+	hit     bool // touched during the last update
+}
+
+// pinball is a table with two flippers, a few bumpers and a plunger lane on
+// the right. Balls that fall past the flippers drain.
+type pinball struct {
+	width    float64
+	height   float64
+	engine   *Engine
+	shader   *ebiten.Shader
+	flippers []*flipper
+	bumpers  []*bumper
+	plunger  *Capsule // floor of the plunger lane
+	spawn    Vec2
+	radius   float64 // of the ball
+	ball     *Circle
+	charge   float64 // how far back the plunger is pulled, 0 to 1
+	score    int
+	balls    int
+}
+
+// newPinball lays out a table in the middle of the screen.
+func newPinball(width, height int, shader *ebiten.Shader) *pinball {
+	w := float64(width)
+	h := float64(height)
+	tw := math.Min(w, h*0.6)
+	x0 := (w - tw) / 2
+	// p maps a fraction of the table's width and the screen's height to
+	// screen space
+	p := func(u, v float64) Vec2 {
+		return Vec2{x0 + u*tw, v * h}
+	}
+	// the top is a half circle as wide as the table
+	top := h*0.02 + tw*0.48
+
+	e := NewEngine(width, height, nil, nil, nil)
+	e.gravity = Vec2{0, 0.3}
+	// flippers move fast, keep them from skipping over the ball
+	e.minSteps = 8
+
+	const wall = 4
+	left := p(0.30, 0.86)
+	right := p(0.70, 0.86)
+	e.addChain(NewChain([]Vec2{left, p(0.02, 0.70), {x0 + tw*0.02, top}}, wall, false))
+	e.addCurve(NewArc(Vec2{x0 + tw*0.5, top}, tw*0.48, math.Pi, math.Pi, wall))
+	e.addChain(NewChain([]Vec2{{x0 + tw*0.98, top}, p(0.98, 1)}, wall, false))
+	e.addChain(NewChain([]Vec2{p(0.90, 0.35), p(0.90, 1)}, wall, false))
+	e.addChain(NewChain([]Vec2{p(0.90, 0.70), right}, wall, false))
+
+	pb := &pinball{
+		width:  w,
+		height: h,
+		engine: e,
+		shader: shader,
+		spawn:  p(0.94, 0.92),
+		radius: tw * 0.02,
+		balls:  pinballBalls,
+	}
+
+	pb.plunger = NewCapsule(p(0.90, 0.96), p(0.98, 0.96), wall, shader)
+	e.capsules = append(e.capsules, pb.plunger)
+	// a gate at the top of the lane lets the ball out but not back in
+	gate := NewCapsule(p(0.90, 0.33), p(0.98, 0.27), wall, shader)
+	gate.oneWay = gate.start.To(gate.end).Normal().Unit()
+	if gate.oneWay.Y > 0 {
+		gate.oneWay = gate.oneWay.Scaled(-1)
+	}
+	e.capsules = append(e.capsules, gate)
+
+	length := tw * 0.17
+	for _, s := range [...]struct {
+		pivot  Vec2
+		rest   float64
+		raised float64
+		keys   []ebiten.Key
+	}{
+		{left, 0.5, -1.0, []ebiten.Key{ebiten.KeyZ, ebiten.KeyLeft}},
+		{right, math.Pi - 0.5, 1.0, []ebiten.Key{ebiten.KeySlash, ebiten.KeyRight}},
+	} {
+		f := &flipper{keys: s.keys, raised: s.raised}
+		tip := s.pivot.Add(Vec2{math.Cos(s.rest), math.Sin(s.rest)}.Scaled(length))
+		f.kinematic = e.addKinematicCapsule(NewCapsule(s.pivot, tip, 8, shader), f, s.pivot)
+		pb.flippers = append(pb.flippers, f)
+	}
+
+	for _, at := range [...]Vec2{p(0.30, 0.38), p(0.62, 0.38), p(0.46, 0.52)} {
+		c := NewCapsule(at, at, tw*0.045, shader)
+		e.capsules = append(e.capsules, c)
+		pb.bumpers = append(pb.bumpers, &bumper{capsule: c})
+	}
+	e.onTouchCapsule = func(_ *Circle, c *Capsule) {
+		for _, b := range pb.bumpers {
+			if b.capsule == c {
+				b.hit = true
+			}
+		}
+	}
+
+	pb.serve()
+	return pb
+}
+
+// serve puts a new ball at the bottom of the plunger lane.
+func (pb *pinball) serve() {
+	pb.ball = NewCircle(pb.spawn.X, pb.spawn.Y, pb.radius, pb.shader)
+	pb.engine.addCircle(pb.ball)
+}
+
+// restart clears the table and starts a new game.
+func (pb *pinball) restart() {
+	if pb.ball != nil && pb.ball.index >= 0 {
+		pb.engine.removeCircle(pb.ball)
+	}
+	pb.score = 0
+	pb.balls = pinballBalls
+	pb.charge = 0
+	pb.serve()
+}
+
+// inLane reports whether the ball is resting in the plunger lane.
+func (pb *pinball) inLane() bool {
+	return pb.ball.pos.X > pb.plunger.start.X && pb.ball.pos.Y > pb.plunger.start.Y-pb.radius*4
+}
+
+func (pb *pinball) update(speed float64) {
+	if pb.balls == 0 {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+			pb.restart()
+		}
+		return
+	}
+
+	for _, f := range pb.flippers {
+		held := false
+		for _, key := range f.keys {
+			held = held || ebiten.IsKeyPressed(key)
+		}
+		f.setHeld(held, f.kinematic.time)
+	}
+
+	// pull the plunger back while down is held and let go to launch
+	if ebiten.IsKeyPressed(ebiten.KeyDown) {
+		pb.charge = math.Min(pb.charge+1/plungerFrames, 1)
+	} else if pb.charge > 0 {
+		if pb.inLane() {
+			launch := Vec2{0, -plungerSpeed * pb.charge * pb.ball.area}
+			pb.engine.ApplyImpulseAt(pb.ball, pb.ball.pos, launch)
+		}
+		pb.charge = 0
+	}
+
+	for _, b := range pb.bumpers {
+		b.hit = false
+	}
+	pb.engine.update(int(pb.width), int(pb.height), speed, 1.0)
+	for _, b := range pb.bumpers {
+		if b.flash > 0 {
+			b.flash--
+			continue
+		}
+		if !b.hit {
+			continue
+		}
+		kick := b.capsule.start.To(pb.ball.pos).Unit().Scaled(bumperKick * pb.ball.area)
+		pb.engine.ApplyImpulseAt(pb.ball, pb.ball.pos, kick)
+		pb.score += bumperScore
+		b.flash = bumperFlash
+	}
+
+	// drain the ball once it falls off the bottom of the screen
+	if pb.ball.pos.Y > pb.height+pb.ball.radius {
+		pb.engine.removeCircle(pb.ball)
+		pb.balls--
+		if pb.balls > 0 {
+			pb.serve()
+		}
+	}
+}
+
+func (pb *pinball) draw(screen *ebiten.Image) {
+	e := pb.engine
+	for _, c := range e.chains {
+		c.Draw(screen, false)
+	}
+	for _, c := range e.curves {
+		c.Draw(screen)
+	}
+	for _, b := range pb.bumpers {
+		c := b.capsule
+		lightness := remap(float64(b.flash), 0, bumperFlash, 0.5, 0.9)
+		clr := colorful.Hcl(20, 0.8, lightness).Clamped()
+		drawCircleOutline(screen, c.start, c.radius/2, c.radius, clr)
+	}
+	for _, c := range e.capsules {
+		if c.start != c.end {
+			c.Draw(screen)
+		}
+	}
+	// the plunger's spring, stretched by how far it is pulled back
+	spring := pb.plunger.start.Add(pb.plunger.end).Scaled(0.5)
+	drawLine(spring, spring.Add(Vec2{0, pb.height * 0.03 * (1 + pb.charge)}), 6, screen, colorful.Hsl(0, 0, 0.5), 1.0)
+	if pb.balls > 0 {
+		pb.ball.Draw(screen)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("Score: ")
+	msg.WriteString(strconv.Itoa(pb.score))
+	msg.WriteString("\nBalls: ")
+	msg.WriteString(strconv.Itoa(pb.balls))
+	msg.WriteString("\n\nZ / Left: left flipper")
+	msg.WriteString("\n/ / Right: right flipper")
+	msg.WriteString("\nDown: pull the plunger")
+	msg.WriteString("\nF1: back to the sandbox")
+	if pb.balls == 0 {
+		msg.WriteString("\n\nGame over, Enter to play again")
+	}
+	ebitenutil.DebugPrintAt(screen, msg.String(), 10, 10)
+}
@@ -0,0 +1,156 @@
+package game
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// NewPortalPair creates two linked portals, one along each segment. Both
+// sides of a portal are open: a circle that goes into the front of one comes
+// out of the back of the other, and the other way around.
+func NewPortalPair(aStart, aEnd, bStart, bEnd Vec2) (*Portal, *Portal) {
+	a := &Portal{start: aStart, end: aEnd, color: colorful.Hcl(50, 0.8, 0.7).Clamped()}
+	b := &Portal{start: bStart, end: bEnd, color: colorful.Hcl(250, 0.8, 0.6).Clamped()}
+	a.other = b
+	b.other = a
+	return a, b
+}
+
+// Portal is a segment that moves circles crossing it to its partner, turning
+// their position and velocity by the difference in the portals' orientation.
+// Portals of different lengths don't scale what goes through.
+type Portal struct {
+	start Vec2
+	end   Vec2
+	other *Portal
+	color colorful.Color
+
+	// reused between frames when drawing circles half way through
+	vertices []ebiten.Vertex
+	indices  []uint16
+}
+
+func (p *Portal) center() Vec2 {
+	return p.start.Add(p.end).Scaled(0.5)
+}
+
+// normal returns the unit normal on the front of the portal.
+func (p *Portal) normal() Vec2 {
+	return p.start.To(p.end).Normal().Unit()
+}
+
+// side returns the signed distance of q in front of the portal.
+func (p *Portal) side(q Vec2) float64 {
+	return p.start.To(q).Dot(p.normal())
+}
+
+// spans reports whether q lies beside the segment, rather than past its
+// ends.
+func (p *Portal) spans(q Vec2) bool {
+	t := p.start.To(q).Dot(p.start.To(p.end))
+	return t >= 0 && t <= p.start.To(p.end).Dot(p.start.To(p.end))
+}
+
+// rotation returns the cosine and sine of the turn from this portal to the
+// other one. It maps the front normal of this portal to the back normal of
+// the other, so a circle moving into the front of this portal comes out of
+// the front of the other one, moving away from it.
+func (p *Portal) rotation() (float64, float64) {
+	angle := p.other.normal().Scaled(-1).Angle() - p.normal().Angle()
+	return math.Cos(angle), math.Sin(angle)
+}
+
+// transformVector turns v from this portal's orientation to the other's.
+func (p *Portal) transformVector(v Vec2) Vec2 {
+	cos, sin := p.rotation()
+	return Vec2{v.X*cos - v.Y*sin, v.X*sin + v.Y*cos}
+}
+
+// transformPoint maps q near this portal to the matching point near the other
+// one.
+func (p *Portal) transformPoint(q Vec2) Vec2 {
+	return p.other.center().Add(p.transformVector(p.center().To(q)))
+}
+
+// togglePortals adds or removes a pair of demo portals, one upright on the
+// left and one flat near the top right.
+func (e *Engine) togglePortals() {
+	if len(e.portals) > 0 {
+		e.portals = e.portals[:0]
+		return
+	}
+	a, b := NewPortalPair(
+		Vec2{e.width * 0.15, e.height * 0.6}, Vec2{e.width * 0.15, e.height * 0.4},
+		Vec2{e.width * 0.7, e.height * 0.15}, Vec2{e.width * 0.9, e.height * 0.15},
+	)
+	e.portals = append(e.portals, a, b)
+}
+
+// teleport moves every circle whose center crossed a portal during the
+// substep to the other portal of the pair.
+func (e *Engine) teleport() {
+	pos := e.bodies.pos
+	prev := e.bodies.prevPos
+	vel := e.bodies.vel
+	for i := range pos {
+		for _, p := range e.portals {
+			d0 := p.side(prev[i])
+			d1 := p.side(pos[i])
+			// starting right on the portal means it just came out of it
+			if d0 == 0 || d0*d1 > 0 {
+				continue
+			}
+			crossing := prev[i].Add(prev[i].To(pos[i]).Scaled(d0 / (d0 - d1)))
+			if !p.spans(crossing) {
+				continue
+			}
+			pos[i] = p.transformPoint(pos[i])
+			prev[i] = p.transformPoint(prev[i])
+			vel[i] = p.transformVector(vel[i])
+			// move the drawn position along too so the motion blur continues
+			// from the other portal
+			circle := e.circles[i]
+			circle.pos = p.transformPoint(circle.pos)
+			break
+		}
+	}
+}
+
+// crossingPortal returns the portal that circle c is partly through, if any.
+func (e *Engine) crossingPortal(c *Circle) *Portal {
+	for _, p := range e.portals {
+		if math.Abs(p.side(c.pos)) < c.radius && p.spans(c.pos) {
+			return p
+		}
+	}
+	return nil
+}
+
+// drawCrossing draws the part of c on its own side of the portal, and the
+// part that poked through coming out of the other portal.
+func (p *Portal) drawCrossing(screen *ebiten.Image, c *Circle) {
+	clr := c.activityColor()
+	img := c.sprite()
+	n := p.normal()
+	if p.side(c.pos) < 0 {
+		n = n.Scaled(-1)
+	}
+	p.vertices, p.indices = drawImageClipped(screen, img, c.pos.Sub(Vec2{c.radius, c.radius}), p.start, n, clr, p.vertices, p.indices)
+
+	// the part behind this portal is in front of the other one, on the
+	// opposite side from where the copy's center lands
+	ghost := p.transformPoint(c.pos)
+	o := p.other
+	on := o.normal()
+	if o.side(ghost) > 0 {
+		on = on.Scaled(-1)
+	}
+	o.vertices, o.indices = drawImageClipped(screen, img, ghost.Sub(Vec2{c.radius, c.radius}), o.start, on, clr, o.vertices, o.indices)
+}
+
+// Draw the portal as a bright line.
+func (p *Portal) Draw(screen *ebiten.Image) {
+	drawLine(p.start, p.end, 4, screen, p.color, 1.0)
+}
@@ -0,0 +1,106 @@
+package game
+
+import (
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// The prediction steps a whole world every frame while aiming, so its cost is
+// capped twice: by how many body substeps it may simulate, which keeps busy
+// worlds from looking as far ahead, and by wall time as a backstop.
+const (
+	predictionSteps  = 120                  // frames looked ahead
+	predictionWork   = 100000               // body substeps per frame, fewer steps are shown past it
+	predictionBudget = 2 * time.Millisecond // per frame, fewer steps are shown past it
+)
+
+// prediction is where the slingshot would send the circle being aimed, and
+// the first circle it would hit, found by stepping a copy of the world ahead.
+type prediction struct {
+	sim           *Engine // scratch copy of the world, made over every frame
+	shot          *Circle // the circle being aimed, in sim
+	target        *Circle // the first circle it touches, in sim
+	launched      []Vec2
+	hit           []Vec2
+	launchedColor colorful.Color
+	hitColor      colorful.Color
+}
+
+// clear forgets the paths, keeping their storage.
+func (p *prediction) clear() {
+	p.launched = p.launched[:0]
+	p.hit = p.hit[:0]
+}
+
+// update predicts the shot of the circle being aimed in e if it were let go
+// at cursor, with the world running at speed.
+func (p *prediction) update(e *Engine, cursor Vec2, speed float64, width, height int) {
+	p.clear()
+	if e.getDynamic() == nil {
+		return
+	}
+	if p.sim == nil {
+		p.sim = &Engine{}
+		p.sim.onTouch = p.touch
+	}
+	sim := p.sim
+	e.cloneInto(sim)
+	p.shot = sim.getDynamic()
+	p.target = nil
+	sim.dynamicRelease(cursor)
+
+	p.launched = append(p.launched, p.shot.pos)
+	start := time.Now()
+	work := 0
+	for step := 0; step < predictionSteps && work < predictionWork && time.Since(start) < predictionBudget; step++ {
+		sim.update(width, height, speed, 1.0)
+		work += sim.steps * len(sim.circles)
+		if p.shot.index < 0 {
+			// merged into another circle
+			break
+		}
+		p.launched = append(p.launched, p.shot.pos)
+		if p.target != nil && p.target.index >= 0 {
+			p.hit = append(p.hit, p.target.pos)
+		}
+	}
+	p.launchedColor = contrastColor(p.shot.color)
+	if p.target != nil {
+		p.hitColor = contrastColor(p.target.color)
+	}
+}
+
+// touch remembers the first circle the shot touches.
+func (p *prediction) touch(a, b *Circle) {
+	if p.target != nil {
+		return
+	}
+	if a == p.shot {
+		p.target = b
+	} else if b == p.shot {
+		p.target = a
+	}
+}
+
+// Draw both paths, fading out towards the end of the prediction.
+func (p *prediction) Draw(screen *ebiten.Image, width, height int) {
+	drawPredictedPath(screen, p.launched, p.launchedColor, width, height)
+	drawPredictedPath(screen, p.hit, p.hitColor, width, height)
+}
+
+func drawPredictedPath(screen *ebiten.Image, points []Vec2, clr colorful.Color, width, height int) {
+	seam := math.Min(float64(width), float64(height)) / 2
+	for k := 1; k < len(points); k++ {
+		a := points[k-1]
+		b := points[k]
+		if a.To(b).Len() > seam {
+			// wrapped around the world
+			continue
+		}
+		alpha := 1 - float64(k)/predictionSteps
+		drawLine(a, b, 2, screen, clr, alpha*0.8)
+	}
+}
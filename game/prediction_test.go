@@ -0,0 +1,35 @@
+package game
+
+import "testing"
+
+func TestPredictionMatchesShot(t *testing.T) {
+	e := newTestEngine(60)
+	e.toggleKinematics(nil)
+	for i := 0; i < 5; i++ {
+		e.update(800, 600, 1, 1)
+	}
+	shot := e.circles[7]
+	e.dynamicNearestPosition(shot.pos)
+	cursor := shot.pos.Add(Vec2{60, 40})
+
+	// at half speed, refreshed a few frames in a row like while aiming
+	var p prediction
+	p.update(e, cursor, 0.5, 800, 600)
+	allocs := testing.AllocsPerRun(30, func() {
+		p.update(e, cursor, 0.5, 800, 600)
+	})
+	if allocs != 0 {
+		t.Errorf("prediction allocated %v times per frame, want 0", allocs)
+	}
+	if len(p.launched) < 2 {
+		t.Fatal("nothing predicted")
+	}
+
+	e.dynamicRelease(cursor)
+	for k := 1; k < len(p.launched); k++ {
+		e.update(800, 600, 0.5, 1)
+		if shot.pos != p.launched[k] {
+			t.Fatalf("frame %v: shot at %v, predicted %v", k, shot.pos, p.launched[k])
+		}
+	}
+}
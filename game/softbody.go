@@ -0,0 +1,200 @@
+package game
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// NewSoftBody creates a deformable blob: a ring of small circles around
+// center, tied together by springs and kept inflated by a pressure force that
+// pushes the ring back towards its starting area.
+func NewSoftBody(center Vec2, radius, memberRadius float64, shader *ebiten.Shader) *SoftBody {
+	// space the members so that neighbours just touch
+	n := int(math.Pi * radius / memberRadius)
+	if n < 3 {
+		n = 3
+	}
+	sb := &SoftBody{
+		pressure: 0.4,
+		color:    randomCircleColor(),
+	}
+	for i := 0; i < n; i++ {
+		angle := twoPi * float64(i) / float64(n)
+		p := center.Add(Vec2{math.Cos(angle), math.Sin(angle)}.Scaled(radius))
+		c := NewCircle(p.X, p.Y, memberRadius, shader)
+		c.hidden = true
+		sb.members = append(sb.members, c)
+	}
+	return sb
+}
+
+// SoftBody is a ring of circles that is simulated as regular circles, so it
+// collides with everything they do, but is drawn as a single filled hull.
+type SoftBody struct {
+	members  []*Circle // in order around the ring
+	restArea float64
+	pressure float64 // stiffness of the area constraint per unit of member mass
+	color    colorful.Color
+
+	// reused between frames when drawing
+	points   []Vec2 // member positions, unwrapped so the ring stays in one piece
+	hull     []Vec2
+	vertices []ebiten.Vertex
+	indices  []uint16
+}
+
+// spring pulls two bodies towards restLength apart.
+type spring struct {
+	a          *Circle
+	b          *Circle
+	restLength float64
+	stiffness  float64
+	damping    float64
+}
+
+// newSpring creates a spring at the current distance between a and b. The
+// stiffness and damping are per unit of mass so that springs behave the same
+// whatever the size of the circles they join.
+func newSpring(a, b *Circle, stiffness, damping float64) spring {
+	mass := math.Min(a.area, b.area)
+	return spring{
+		a:          a,
+		b:          b,
+		restLength: a.pos.To(b.pos).Len(),
+		stiffness:  stiffness * mass,
+		damping:    damping * mass,
+	}
+}
+
+func (e *Engine) addSoftBody(sb *SoftBody) {
+	for _, c := range sb.members {
+		e.addCircle(c)
+	}
+	n := len(sb.members)
+	for i, c := range sb.members {
+		// neighbours hold the ring together and the next but one resist
+		// bending, so the ring doesn't crumple when hit
+		e.springs = append(e.springs, newSpring(c, sb.members[(i+1)%n], 0.3, 0.1))
+		e.springs = append(e.springs, newSpring(c, sb.members[(i+2)%n], 0.1, 0.05))
+	}
+	sb.restArea = math.Abs(sb.signedArea(e, e.bodies.pos))
+	e.softBodies = append(e.softBodies, sb)
+}
+
+// applySprings adds the acceleration from every spring to acc.
+func (e *Engine) applySprings(pos, vel, acc []Vec2) {
+	invMass := e.bodies.invMass
+	for k := range e.springs {
+		s := &e.springs[k]
+		a := s.a.index
+		b := s.b.index
+		d := e.delta(pos[a], pos[b])
+		length := d.Len()
+		if length == 0 {
+			continue
+		}
+		dir := d.Scaled(1.0 / length)
+		relVel := vel[a].To(vel[b]).Dot(dir)
+		force := dir.Scaled(s.stiffness*(length-s.restLength) + s.damping*relVel)
+		acc[a] = acc[a].Add(force.Scaled(invMass[a]))
+		acc[b] = acc[b].Sub(force.Scaled(invMass[b]))
+	}
+}
+
+// signedArea of the ring polygon using the shoelace formula. The corners are
+// taken relative to the first member, following the edges, so a ring that
+// straddles a seam of a wrapping world keeps its area.
+func (sb *SoftBody) signedArea(e *Engine, pos []Vec2) float64 {
+	area := 0.0
+	corner := Vec2{0, 0}
+	for i, c := range sb.members[:len(sb.members)-1] {
+		next := corner.Add(e.delta(pos[c.index], pos[sb.members[i+1].index]))
+		area += corner.Cross(next)
+		corner = next
+	}
+	return area * 0.5
+}
+
+// applyPressure pushes every edge of the ring along its outward normal in
+// proportion to how far the enclosed area is below the rest area.
+func (sb *SoftBody) applyPressure(e *Engine, pos, acc []Vec2) {
+	invMass := e.bodies.invMass
+	area := sb.signedArea(e, pos)
+	// the outward normal depends on the winding of the ring
+	sign := 1.0
+	if area < 0 {
+		sign = -1.0
+	}
+	mass := sb.members[0].area
+	p := sb.pressure * mass * (sb.restArea - math.Abs(area)) / sb.restArea
+	n := len(sb.members)
+	for i, c := range sb.members {
+		a := c.index
+		b := sb.members[(i+1)%n].index
+		// the normal has the length of the edge, so longer edges get more force
+		force := e.delta(pos[a], pos[b]).Normal().Scaled(-sign * p * 0.5)
+		acc[a] = acc[a].Add(force.Scaled(invMass[a]))
+		acc[b] = acc[b].Add(force.Scaled(invMass[b]))
+	}
+}
+
+// Draw the soft body as a smooth filled hull around its members.
+func (sb *SoftBody) Draw(screen *ebiten.Image, e *Engine) {
+	n := len(sb.members)
+	sb.points = append(sb.points[:0], sb.members[0].pos)
+	for i, c := range sb.members[1:] {
+		sb.points = append(sb.points, sb.points[i].Add(e.delta(sb.members[i].pos, c.pos)))
+	}
+	centroid := Vec2{0, 0}
+	activity := 0.0
+	for i, c := range sb.members {
+		centroid = centroid.Add(sb.points[i])
+		activity += c.activity
+	}
+	centroid = centroid.Scaled(1.0 / float64(n))
+	activity /= float64(n)
+
+	// Catmull-Rom spline through the outer edge of the members
+	const subdivisions = 4
+	sb.hull = sb.hull[:0]
+	for i := range sb.members {
+		p0 := sb.outerPoint(i-1, centroid)
+		p1 := sb.outerPoint(i, centroid)
+		p2 := sb.outerPoint(i+1, centroid)
+		p3 := sb.outerPoint(i+2, centroid)
+		for s := 0; s < subdivisions; s++ {
+			sb.hull = append(sb.hull, catmullRom(p0, p1, p2, p3, float64(s)/subdivisions))
+		}
+	}
+
+	hue, _, _ := sb.color.Hcl()
+	chroma := remap(math.Min(activity, 1), 0, 1, 0.45, 1)
+	lightness := remap(math.Min(activity, 1), 0, 1, 0.45, 0.9)
+	clr := colorful.Hcl(hue, chroma, lightness).Clamped()
+	sb.vertices, sb.indices = drawPolygon(sb.hull, centroid, screen, clr, 1.0, sb.vertices, sb.indices)
+}
+
+// outerPoint returns the point on the edge of member i that faces away from
+// the centroid. i wraps around the ring.
+func (sb *SoftBody) outerPoint(i int, centroid Vec2) Vec2 {
+	n := len(sb.members)
+	i = ((i % n) + n) % n
+	p := sb.points[i]
+	out := centroid.To(p)
+	if out.Len() == 0 {
+		return p
+	}
+	return p.Add(out.Unit().Scaled(sb.members[i].radius))
+}
+
+// catmullRom interpolates between p1 and p2 at t in 0..1.
+func catmullRom(p0, p1, p2, p3 Vec2, t float64) Vec2 {
+	t2 := t * t
+	t3 := t2 * t
+	f := func(a, b, c, d float64) float64 {
+		return 0.5 * (2*b + (c-a)*t + (2*a-5*b+4*c-d)*t2 + (3*b-a-3*c+d)*t3)
+	}
+	return Vec2{f(p0.X, p1.X, p2.X, p3.X), f(p0.Y, p1.Y, p2.Y, p3.Y)}
+}
@@ -20,7 +20,25 @@ type SpeedControl struct {
 }
 
 func (s *SpeedControl) multiplier() float64 {
-	switch s.control {
+	return speedMultiplier(s.control)
+}
+
+// advanceMultiplier is the speed to step frames at while paused, the one from
+// before pausing.
+func (s *SpeedControl) advanceMultiplier() float64 {
+	return speedMultiplier(s.prev)
+}
+
+// pause stops time, remembering the speed to resume and step frames at.
+func (s *SpeedControl) pause() {
+	if !s.paused() {
+		s.prev = s.control
+		s.control = 0
+	}
+}
+
+func speedMultiplier(control int) float64 {
+	switch control {
 	case 0:
 		return 0.0
 	case 1:
@@ -40,7 +58,9 @@ func (s *SpeedControl) paused() bool {
 func (s *SpeedControl) update() {
 	// Adjust game speed
 	if inpututil.IsKeyJustPressed(ebiten.KeyComma) {
-		if s.control > 0 {
+		if s.control == 1 {
+			s.pause()
+		} else if s.control > 0 {
 			s.control--
 		}
 	}
@@ -53,8 +73,7 @@ func (s *SpeedControl) update() {
 		if s.paused() {
 			s.control = s.prev
 		} else {
-			s.prev = s.control
-			s.control = 0
+			s.pause()
 		}
 	}
 	if inpututil.IsKeyJustPressed(ebiten.Key1) {
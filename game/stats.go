@@ -0,0 +1,148 @@
+package game
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// Stats are diagnostics measured during the last engine update. Mass is the
+// circle area, the same as in the collision response.
+type Stats struct {
+	KineticEnergy   float64
+	Momentum        Vec2
+	AngularMomentum float64 // about the center of the world
+	ContactsPerStep float64
+	MaxPenetration  float64 // deepest overlap found before it was resolved
+}
+
+// Stats returns the diagnostics measured during the last update.
+func (e *Engine) Stats() Stats {
+	return e.stats
+}
+
+// addContact records a contact and how deep the bodies overlapped.
+func (e *Engine) addContact(depth float64) {
+	e.contacts++
+	e.stats.MaxPenetration = math.Max(e.stats.MaxPenetration, depth)
+}
+
+// measure computes the conserved quantities at the end of an update.
+func (e *Engine) measure() {
+	pos := e.bodies.pos
+	vel := e.bodies.vel
+	center := Vec2{e.width / 2, e.height / 2}
+
+	s := &e.stats
+	s.KineticEnergy = 0
+	s.Momentum = Vec2{0, 0}
+	s.AngularMomentum = 0
+	for i, invMass := range e.bodies.invMass {
+		if invMass == 0 {
+			continue
+		}
+		mass := 1.0 / invMass
+		momentum := vel[i].Scaled(mass)
+		s.KineticEnergy += 0.5 * mass * vel[i].Dot(vel[i])
+		s.Momentum = s.Momentum.Add(momentum)
+		s.AngularMomentum += center.To(pos[i]).Cross(momentum)
+	}
+	s.ContactsPerStep = float64(e.contacts) / float64(e.steps)
+}
+
+const sparklineSamples = 240
+
+// sparkline keeps the recent history of a single value.
+type sparkline struct {
+	label  string
+	values [sparklineSamples]float64
+	next   int
+	count  int
+}
+
+func (s *sparkline) push(v float64) {
+	s.values[s.next] = v
+	s.next = (s.next + 1) % sparklineSamples
+	if s.count < sparklineSamples {
+		s.count++
+	}
+}
+
+// at returns the i'th oldest value.
+func (s *sparkline) at(i int) float64 {
+	start := s.next - s.count
+	if start < 0 {
+		start += sparklineSamples
+	}
+	return s.values[(start+i)%sparklineSamples]
+}
+
+// draw the label, latest value and history scaled to fit in a w by h box.
+func (s *sparkline) draw(screen *ebiten.Image, x, y, w, h float64) {
+	if s.count == 0 {
+		return
+	}
+	low := math.MaxFloat64
+	high := -math.MaxFloat64
+	for i := 0; i < s.count; i++ {
+		low = math.Min(low, s.at(i))
+		high = math.Max(high, s.at(i))
+	}
+	latest := s.at(s.count - 1)
+	ebitenutil.DebugPrintAt(screen, s.label+": "+strconv.FormatFloat(latest, 'g', 5, 64), int(x), int(y))
+
+	top := y + 16
+	plotY := func(v float64) float64 {
+		if high == low {
+			return top + h*0.5
+		}
+		return top + h - remap(v, low, high, 0, h)
+	}
+	step := w / float64(sparklineSamples-1)
+	prevX := x
+	prevY := plotY(s.at(0))
+	for i := 1; i < s.count; i++ {
+		px := x + float64(i)*step
+		py := plotY(s.at(i))
+		ebitenutil.DrawLine(screen, prevX, prevY, px, py, color.RGBA{120, 200, 255, 255})
+		prevX = px
+		prevY = py
+	}
+}
+
+// statsPanel shows the history of the engine stats as sparklines.
+type statsPanel struct {
+	lines [5]sparkline
+}
+
+func newStatsPanel() *statsPanel {
+	p := &statsPanel{}
+	p.lines[0].label = "Kinetic energy"
+	p.lines[1].label = "Momentum"
+	p.lines[2].label = "Angular momentum"
+	p.lines[3].label = "Contacts/step"
+	p.lines[4].label = "Max penetration"
+	return p
+}
+
+func (p *statsPanel) record(s Stats) {
+	p.lines[0].push(s.KineticEnergy)
+	p.lines[1].push(s.Momentum.Len())
+	p.lines[2].push(s.AngularMomentum)
+	p.lines[3].push(s.ContactsPerStep)
+	p.lines[4].push(s.MaxPenetration)
+}
+
+// draw the panel with its upper left corner at x, y.
+func (p *statsPanel) draw(screen *ebiten.Image, x, y float64) {
+	width := 260.0
+	lineHeight := 30.0
+	rowHeight := lineHeight + 24
+	ebitenutil.DrawRect(screen, x, y, width, rowHeight*float64(len(p.lines))+8, color.RGBA{0, 0, 0, 160})
+	for i := range p.lines {
+		p.lines[i].draw(screen, x+8, y+4+float64(i)*rowHeight, width-16, lineHeight)
+	}
+}
@@ -0,0 +1,123 @@
+package game
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// surfaceGrip is how much of the difference between a circle's sliding speed
+// and a moving surface is taken away per contact.
+const surfaceGrip = 0.25
+
+// surfaceSpeeds are the speeds cycled through when spinning a surface.
+var surfaceSpeeds = [...]float64{0, 4, -4}
+
+// surfaceVelocity returns the velocity of a surface with the given outward
+// normal that moves clockwise around its shape at speed.
+func surfaceVelocity(normal Vec2, speed float64) Vec2 {
+	return normal.Normal().Scaled(speed)
+}
+
+// nextSurfaceSpeed returns the speed after s in surfaceSpeeds.
+func nextSurfaceSpeed(s float64) float64 {
+	for k, speed := range surfaceSpeeds {
+		if speed == s {
+			return surfaceSpeeds[(k+1)%len(surfaceSpeeds)]
+		}
+	}
+	return surfaceSpeeds[0]
+}
+
+// colliderAt returns the capsule or rectangle under pos. At most one of them
+// is set, and neither when there is nothing there. The walls around the world
+// are left out.
+func (e *Engine) colliderAt(pos Vec2) (*Capsule, *collisionRect) {
+	for _, c := range e.capsules {
+		t := closestOnSegment(c.start, c.end, pos)
+		if c.start.Add(c.start.To(c.end).Scaled(t)).To(pos).Len() < c.radius {
+			return c, nil
+		}
+	}
+	for _, r := range e.collisionRects {
+		if r.boundary {
+			continue
+		}
+		if pos.X >= r.upperLeft.X && pos.X <= r.lowerRight.X && pos.Y >= r.upperLeft.Y && pos.Y <= r.lowerRight.Y {
+			return nil, r
+		}
+	}
+	return nil, nil
+}
+
+// spinSurfaceAt changes the surface speed of the capsule or rectangle under
+// pos, returning false when there is none.
+func (e *Engine) spinSurfaceAt(pos Vec2) bool {
+	c, r := e.colliderAt(pos)
+	switch {
+	case c != nil:
+		c.surfaceSpeed = nextSurfaceSpeed(c.surfaceSpeed)
+	case r != nil:
+		r.surfaceSpeed = nextSurfaceSpeed(r.surfaceSpeed)
+	default:
+		return false
+	}
+	return true
+}
+
+// drawSurfaceArrows draws arrows along every moving surface, scrolling with
+// it so the direction and speed are easy to see.
+func drawSurfaceArrows(screen *ebiten.Image, e *Engine, time int) {
+	clr := colorful.Hcl(140, 0.7, 0.75).Clamped()
+	for _, c := range e.capsules {
+		if c.surfaceSpeed == 0 {
+			continue
+		}
+		n := c.start.To(c.end).Normal().Unit()
+		for _, side := range [2]Vec2{n, n.Scaled(-1)} {
+			offset := side.Scaled(c.radius + 6)
+			drawBeltArrows(screen, c.start.Add(offset), c.end.Add(offset), surfaceVelocity(side, c.surfaceSpeed), time, clr)
+		}
+	}
+	for _, r := range e.collisionRects {
+		if r.surfaceSpeed == 0 {
+			continue
+		}
+		const gap = 6
+		ul := r.upperLeft
+		lr := r.lowerRight
+		edges := [4][3]Vec2{
+			{{ul.X, ul.Y - gap}, {lr.X, ul.Y - gap}, {0, -1}},
+			{{lr.X + gap, ul.Y}, {lr.X + gap, lr.Y}, {1, 0}},
+			{{ul.X, lr.Y + gap}, {lr.X, lr.Y + gap}, {0, 1}},
+			{{ul.X - gap, ul.Y}, {ul.X - gap, lr.Y}, {-1, 0}},
+		}
+		for _, edge := range edges {
+			drawBeltArrows(screen, edge[0], edge[1], surfaceVelocity(edge[2], r.surfaceSpeed), time, clr)
+		}
+	}
+}
+
+// drawBeltArrows draws evenly spaced arrows from a to b pointing along
+// velocity.
+func drawBeltArrows(screen *ebiten.Image, a, b, velocity Vec2, time int, clr colorful.Color) {
+	const spacing = 40.0
+	length := a.To(b).Len()
+	if length == 0 {
+		return
+	}
+	dir := a.To(b).Scaled(1.0 / length)
+	speed := velocity.Dot(dir)
+	shift := math.Mod(float64(time)*speed, spacing)
+	if shift < 0 {
+		shift += spacing
+	}
+	head := velocity.Unit().Scaled(5)
+	for d := shift; d < length; d += spacing {
+		tip := a.Add(dir.Scaled(d))
+		drawLine(tip.Sub(head.Scaled(2)), tip, 2, screen, clr, 0.8)
+		drawLine(tip, tip.Sub(head).Add(head.Normal()), 2, screen, clr, 0.8)
+		drawLine(tip, tip.Sub(head).Sub(head.Normal()), 2, screen, clr, 0.8)
+	}
+}
@@ -0,0 +1,81 @@
+// Package level traces collision geometry from bitmap masks. It only deals in
+// pixels and plain polygons, so it has no dependency on the game or ebiten.
+package level
+
+import "image"
+
+// Point is a position in pixels of a mask.
+type Point struct {
+	X, Y float64
+}
+
+// Mask is the solid pixels of a mask image.
+type Mask struct {
+	W, H  int
+	solid []bool
+}
+
+// NewMask reads the mask from img, dark opaque pixels are solid and
+// transparent ones are empty whatever their color.
+func NewMask(img image.Image) *Mask {
+	b := img.Bounds()
+	m := &Mask{W: b.Dx(), H: b.Dy()}
+	m.solid = make([]bool, m.W*m.H)
+	for y := 0; y < m.H; y++ {
+		for x := 0; x < m.W; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			// dark pixels are solid, the colors are premultiplied so
+			// transparent ones would read as black
+			m.solid[y*m.W+x] = a >= 0x8000 && (r+g+bl)/3 < 0x8000
+		}
+	}
+	return m
+}
+
+// At reports whether the pixel at x, y is solid, everything outside the mask
+// is empty.
+func (m *Mask) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= m.W || y >= m.H {
+		return false
+	}
+	return m.solid[y*m.W+x]
+}
+
+// TakeRectangles finds the solid shapes that fill their bounding box,
+// removes them from the mask and returns their bounds.
+func (m *Mask) TakeRectangles() []image.Rectangle {
+	var boxes []image.Rectangle
+	seen := make([]bool, len(m.solid))
+	var stack []image.Point
+	var shape []image.Point
+	for start := range m.solid {
+		if !m.solid[start] || seen[start] {
+			continue
+		}
+		// flood fill the shape
+		seen[start] = true
+		stack = append(stack[:0], image.Point{start % m.W, start / m.W})
+		shape = shape[:0]
+		box := image.Rectangle{Min: stack[0], Max: stack[0].Add(image.Point{1, 1})}
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			shape = append(shape, p)
+			box = box.Union(image.Rectangle{Min: p, Max: p.Add(image.Point{1, 1})})
+			for _, d := range [4]image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				q := p.Add(d)
+				if m.At(q.X, q.Y) && !seen[q.Y*m.W+q.X] {
+					seen[q.Y*m.W+q.X] = true
+					stack = append(stack, q)
+				}
+			}
+		}
+		if len(shape) == box.Dx()*box.Dy() {
+			boxes = append(boxes, box)
+			for _, p := range shape {
+				m.solid[p.Y*m.W+p.X] = false
+			}
+		}
+	}
+	return boxes
+}
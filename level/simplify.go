@@ -0,0 +1,64 @@
+package level
+
+import "math"
+
+// SimplifyLoop removes points of a closed loop that are within tolerance of
+// the line through their neighbours, with Ramer-Douglas-Peucker.
+func SimplifyLoop(loop []Point, tolerance float64) []Point {
+	if len(loop) < 4 {
+		return loop
+	}
+	// split at the point farthest from the first so both halves are open
+	far := 0
+	for k, p := range loop {
+		if distance(loop[0], p) > distance(loop[0], loop[far]) {
+			far = k
+		}
+	}
+	closed := append(append([]Point{}, loop...), loop[0])
+	a := simplifyLine(closed[:far+1], tolerance)
+	b := simplifyLine(closed[far:], tolerance)
+	// both halves include the split points, drop the repeats
+	return append(a[:len(a)-1], b[:len(b)-1]...)
+}
+
+// simplifyLine is Ramer-Douglas-Peucker on an open polyline, it keeps both
+// ends.
+func simplifyLine(line []Point, tolerance float64) []Point {
+	if len(line) < 3 {
+		return append([]Point{}, line...)
+	}
+	first := line[0]
+	last := line[len(line)-1]
+	worst := 0
+	worstDist := 0.0
+	for k := 1; k < len(line)-1; k++ {
+		d := distanceToSegment(first, last, line[k])
+		if d > worstDist {
+			worst = k
+			worstDist = d
+		}
+	}
+	if worstDist <= tolerance {
+		return []Point{first, last}
+	}
+	a := simplifyLine(line[:worst+1], tolerance)
+	b := simplifyLine(line[worst:], tolerance)
+	return append(a[:len(a)-1], b...)
+}
+
+func distance(a, b Point) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
+
+// distanceToSegment returns how far p is from the closest point of the
+// segment from a to b.
+func distanceToSegment(a, b, p Point) float64 {
+	abX, abY := b.X-a.X, b.Y-a.Y
+	length2 := abX*abX + abY*abY
+	if length2 == 0 {
+		return distance(a, p)
+	}
+	t := math.Min(math.Max(((p.X-a.X)*abX+(p.Y-a.Y)*abY)/length2, 0), 1)
+	return distance(Point{a.X + abX*t, a.Y + abY*t}, p)
+}
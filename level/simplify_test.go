@@ -0,0 +1,55 @@
+package level
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimplifyLoop(t *testing.T) {
+	// a 4 by 4 square traced a point per unit, with a bump of 0.3 in the
+	// middle of the top side
+	square := []Point{
+		{0, 0}, {1, 0}, {2, -0.3}, {3, 0}, {4, 0},
+		{4, 1}, {4, 2}, {4, 3}, {4, 4},
+		{3, 4}, {2, 4}, {1, 4}, {0, 4},
+		{0, 3}, {0, 2}, {0, 1},
+	}
+	for _, tc := range []struct {
+		name      string
+		loop      []Point
+		tolerance float64
+		want      []Point
+	}{
+		{
+			name:      "bump within tolerance is dropped",
+			loop:      square,
+			tolerance: 0.5,
+			want:      []Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}},
+		},
+		{
+			name:      "bump past tolerance is kept",
+			loop:      square,
+			tolerance: 0.2,
+			want:      []Point{{0, 0}, {2, -0.3}, {4, 0}, {4, 4}, {0, 4}},
+		},
+		{
+			name:      "zero tolerance only drops points in line",
+			loop:      square,
+			tolerance: 0,
+			want:      []Point{{0, 0}, {1, 0}, {2, -0.3}, {3, 0}, {4, 0}, {4, 4}, {0, 4}},
+		},
+		{
+			name:      "triangles are left alone",
+			loop:      []Point{{0, 0}, {1, 0}, {0, 1}},
+			tolerance: 10,
+			want:      []Point{{0, 0}, {1, 0}, {0, 1}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SimplifyLoop(append([]Point{}, tc.loop...), tc.tolerance)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package level
+
+import "image"
+
+// Trace the boundaries between solid and empty pixels with marching squares.
+// Every loop is returned in pixel units, with the outline passing halfway
+// between the centers of solid and empty pixels.
+func (m *Mask) Trace() [][]Point {
+	// Corners of a cell are pixel centers, clockwise from the top left, and
+	// edge k runs from corner k to corner k+1. Points are kept in half pixels
+	// so the middle of every edge is a whole number.
+	corners := [4]image.Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	next := map[image.Point]image.Point{}
+	for y := -1; y < m.H; y++ {
+		for x := -1; x < m.W; x++ {
+			var solid [4]bool
+			var mids [4]image.Point
+			for k, c := range corners {
+				solid[k] = m.At(x+c.X, y+c.Y)
+				d := corners[(k+1)%4]
+				mids[k] = image.Point{2*x + c.X + d.X, 2*y + c.Y + d.Y}
+			}
+			// Every edge leaving solid joins the edge entering solid just
+			// before it, which walks each outline with solid on the same
+			// side and keeps diagonal solid corners apart at saddles.
+			for k := range corners {
+				if !solid[k] || solid[(k+1)%4] {
+					continue
+				}
+				for j := (k + 3) % 4; j != k; j = (j + 3) % 4 {
+					if !solid[j] && solid[(j+1)%4] {
+						next[mids[k]] = mids[j]
+						break
+					}
+				}
+			}
+		}
+	}
+
+	var loops [][]Point
+	for len(next) > 0 {
+		var start image.Point
+		for p := range next {
+			start = p
+			break
+		}
+		var loop []Point
+		p := start
+		for {
+			loop = append(loop, Point{float64(p.X)/2 + 0.5, float64(p.Y)/2 + 0.5})
+			q, ok := next[p]
+			if !ok {
+				break
+			}
+			delete(next, p)
+			p = q
+			if p == start {
+				break
+			}
+		}
+		loops = append(loops, loop)
+	}
+	return loops
+}
@@ -0,0 +1,109 @@
+package level
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// maskFrom builds a mask from rows of text, where # is solid.
+func maskFrom(rows ...string) *Mask {
+	m := &Mask{W: len(rows[0]), H: len(rows)}
+	for _, row := range rows {
+		for _, c := range row {
+			m.solid = append(m.solid, c == '#')
+		}
+	}
+	return m
+}
+
+// normalize starts every loop at its top left point and orders the loops by
+// that point, so loops traced from different starts compare equal.
+func normalize(loops [][]Point) [][]Point {
+	less := func(a, b Point) bool {
+		return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+	}
+	for k, loop := range loops {
+		first := 0
+		for i, p := range loop {
+			if less(p, loop[first]) {
+				first = i
+			}
+		}
+		loops[k] = append(append([]Point{}, loop[first:]...), loop[:first]...)
+	}
+	sort.Slice(loops, func(i, j int) bool {
+		return less(loops[i][0], loops[j][0])
+	})
+	return loops
+}
+
+func TestTrace(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mask *Mask
+		want [][]Point
+	}{
+		{
+			name: "empty",
+			mask: maskFrom("..", ".."),
+			want: nil,
+		},
+		{
+			name: "single pixel",
+			mask: maskFrom("#"),
+			want: [][]Point{
+				{{0.5, 0}, {1, 0.5}, {0.5, 1}, {0, 0.5}},
+			},
+		},
+		{
+			name: "square",
+			mask: maskFrom("##", "##"),
+			want: [][]Point{
+				{{0.5, 0}, {1.5, 0}, {2, 0.5}, {2, 1.5}, {1.5, 2}, {0.5, 2}, {0, 1.5}, {0, 0.5}},
+			},
+		},
+		{
+			name: "diagonal pixels stay apart",
+			mask: maskFrom("#.", ".#"),
+			want: [][]Point{
+				{{0.5, 0}, {1, 0.5}, {0.5, 1}, {0, 0.5}},
+				{{1.5, 1}, {2, 1.5}, {1.5, 2}, {1, 1.5}},
+			},
+		},
+		{
+			name: "ring has an outline and a hole",
+			mask: maskFrom("###", "#.#", "###"),
+			want: [][]Point{
+				{{0.5, 0}, {1.5, 0}, {2.5, 0}, {3, 0.5}, {3, 1.5}, {3, 2.5}, {2.5, 3}, {1.5, 3}, {0.5, 3}, {0, 2.5}, {0, 1.5}, {0, 0.5}},
+				{{1.5, 1}, {1, 1.5}, {1.5, 2}, {2, 1.5}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := normalize(tc.mask.Trace())
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTraceTransparentBackground(t *testing.T) {
+	// a dark square on a transparent background
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 1; y < 3; y++ {
+		for x := 1; x < 3; x++ {
+			img.Set(x, y, color.Black)
+		}
+	}
+	got := normalize(NewMask(img).Trace())
+	want := [][]Point{
+		{{1.5, 1}, {2.5, 1}, {3, 1.5}, {3, 2.5}, {2.5, 3}, {1.5, 3}, {1, 2.5}, {1, 1.5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
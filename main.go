@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -14,7 +15,10 @@ var (
 	screenHeight = 1040 // 640
 )
 
+var level = flag.String("level", "", "PNG mask to trace collision geometry from, dark pixels are solid")
+
 func main() {
+	flag.Parse()
 
 	// In this test, window size is equal to screen size, so no pixelation
 	// or stretching will occur.
@@ -22,6 +26,11 @@ func main() {
 
 	ebiten.SetWindowTitle("2D Collisions")
 	game := game.NewGame(screenWidth, screenHeight)
+	if *level != "" {
+		if err := game.LoadLevel(*level); err != nil {
+			log.Fatal("Level failed: ", err)
+		}
+	}
 	if err := ebiten.RunGame(game); err != nil {
 		log.Fatal(err)
 	}
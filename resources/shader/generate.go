@@ -1,3 +1,4 @@
 package shader
 
 //go:generate file2byteslice -package=shader -input=circle.go -output=circle_go.go -var=Circle
+//go:generate file2byteslice -package=shader -input=metaball.go -output=metaball_go.go -var=Metaball
@@ -0,0 +1,22 @@
+// +build ignore
+
+package shader
+
+var Color vec3
+
+func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+	// Sum of the particle falloffs drawn into the source image
+	field := imageSrc0UnsafeAt(texCoord).a
+
+	// The liquid surface is where the field crosses the threshold, with a
+	// narrow band of falloff to keep the edge smooth
+	surface := smoothstep(0.45, 0.55, field)
+
+	// Lighten where the field is thin, so the liquid has a bright rim
+	rim := 1.0 - smoothstep(0.55, 0.9, field)
+	clr := Color + vec3(0.3)*rim
+
+	// Output is premultiplied alpha
+	return vec4(clr*surface, surface)
+}